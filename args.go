@@ -0,0 +1,64 @@
+package reflectflag
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// OmitDefaults makes ToArgs leave out every flag whose value is the same as
+// the default it would be given by RegisterFlags with a zero struct.
+func OmitDefaults() Option {
+	return omitDefaultsOpt{}
+}
+
+type omitDefaultsOpt struct{}
+
+func (omitDefaultsOpt) set(opts *options) {
+	opts.omitDefaults = true
+}
+
+// ToArgs returns command line arguments of the form "--name=value" that set
+// every flag of s, which may be a struct or a pointer to a struct, to the value
+// of its field. Values are encoded with the String method of the flag, so
+// parsing the arguments into a FlagSet populated by RegisterFlags with the
+// same Options and then calling LoadFromFlags reproduces s. Empty Optional
+// fields, and nil pointer fields with NilIfUnset, are left out. The arguments
+// are in the order of the fields of s.
+func ToArgs(s interface{}, opts ...Option) ([]string, error) {
+	v := reflect.ValueOf(s)
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("unable to convert flags for %q: %w", reflect.TypeOf(s), ErrNotStruct)
+	}
+	o := getOpts(opts...)
+	p, err := getPlan(v.Type(), o, "convert")
+	if err != nil {
+		return nil, err
+	}
+	zero := reflect.Zero(v.Type())
+	var args []string
+	for i := range p.fields {
+		fp := &p.fields[i]
+		fg := fp.newGetter(v.FieldByIndex(fp.index), o)
+		if ov, ok := fg.(*optionalValue); ok && !ov.set {
+			// An unset value can only be expressed by omitting the flag.
+			continue
+		}
+		val := fg.String()
+		if o.omitDefaults {
+			def := fp.newGetter(zero.FieldByIndex(fp.index), o)
+			if fp.hasDef {
+				if err := def.Set(fp.def); err != nil {
+					return nil, &FieldError{Op: "convert", Type: p.typ, Field: fp.path, Flag: fp.name, Err: fmt.Errorf("invalid default value %q: %w", fp.def, err)}
+				}
+			}
+			if def.String() == val {
+				continue
+			}
+		}
+		args = append(args, "--"+fp.name+"="+val)
+	}
+	return args, nil
+}
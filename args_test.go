@@ -0,0 +1,115 @@
+package reflectflag
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestToArgs(t *testing.T) {
+	type config struct {
+		Name    string            `flag:"name"`
+		Port    *int              `flag:"port" default:"80"`
+		Timeout time.Duration     `flag:"timeout" default:"5s"`
+		Hosts   []string          `flag:"hosts"`
+		Labels  map[string]string `flag:"labels"`
+		Verbose bool              `flag:"verbose"`
+	}
+	port := 80
+	cfg := config{
+		Name:    "a b",
+		Port:    &port,
+		Timeout: time.Minute,
+		Hosts:   []string{"x", "y,z"},
+		Labels:  map[string]string{"env": "prod"},
+	}
+	args, err := ToArgs(&cfg, FlagPrefix("app."))
+	if err != nil {
+		t.Fatalf("unexpected error from ToArgs: %v", err)
+	}
+	want := []string{
+		"--app.name=a b",
+		"--app.port=80",
+		"--app.timeout=1m0s",
+		`--app.hosts=x,"y,z"`,
+		"--app.labels=env=prod",
+		"--app.verbose=false",
+	}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("unexpected output from ToArgs; got %q want %q", args, want)
+	}
+	fs := newFlagSet()
+	if err := RegisterFlags(fs, config{}, FlagPrefix("app.")); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	var got config
+	if err := LoadFromFlags(fs, &got, FlagPrefix("app.")); err != nil {
+		t.Fatal(err)
+	}
+	if !deepEqual(got, cfg) {
+		t.Errorf("round trip through ToArgs; got %#v want %#v", got, cfg)
+	}
+
+	args, err = ToArgs(cfg, OmitDefaults())
+	if err != nil {
+		t.Fatalf("unexpected error from ToArgs: %v", err)
+	}
+	want = []string{
+		"--name=a b",
+		"--timeout=1m0s",
+		`--hosts=x,"y,z"`,
+		"--labels=env=prod",
+	}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("unexpected output from ToArgs with OmitDefaults; got %q want %q", args, want)
+	}
+	// The nil Port differs from its default of 80.
+	want = []string{"--port=0"}
+	if args, err := ToArgs(config{Timeout: 5 * time.Second}, OmitDefaults()); err != nil || !reflect.DeepEqual(args, want) {
+		t.Errorf("unexpected output from ToArgs with OmitDefaults; got %q, %v", args, err)
+	}
+
+	if _, err := ToArgs(1); err == nil {
+		t.Errorf("expected an error from ToArgs with a non-struct")
+	}
+}
+
+func TestToArgsRoundTrip(t *testing.T) {
+	type config struct {
+		Name   string            `flag:"name"`
+		Hosts  []string          `flag:"hosts"`
+		Semi   []string          `flag:"semi,sep=;"`
+		Labels map[string]string `flag:"labels"`
+	}
+	for _, cfg := range []config{
+		{},
+		{Name: "", Hosts: []string{""}, Semi: []string{""}},
+		{Hosts: []string{"", ""}, Semi: []string{"a;b", ""}},
+		{Name: `say "hi", =`, Hosts: []string{"a,b", `"q"`, "x=y", " "}},
+		{Semi: []string{`"`, "a,b"}},
+		{Labels: map[string]string{"": ""}},
+		{Labels: map[string]string{"a=b": "c=d", `"k"`: `"v"`, "x,y": "1,2", `"`: "=", "e": ""}},
+	} {
+		args, err := ToArgs(cfg)
+		if err != nil {
+			t.Fatalf("ToArgs(%#v): unexpected error: %v", cfg, err)
+		}
+		fs := newFlagSet()
+		if err := RegisterFlags(fs, config{}); err != nil {
+			t.Fatal(err)
+		}
+		if err := fs.Parse(args); err != nil {
+			t.Fatalf("Parse(%q): unexpected error: %v", args, err)
+		}
+		var got config
+		if err := LoadFromFlags(fs, &got); err != nil {
+			t.Fatalf("LoadFromFlags(%q): unexpected error: %v", args, err)
+		}
+		if !deepEqual(got, cfg) {
+			t.Errorf("round trip through %q; got %#v want %#v", args, got, cfg)
+		}
+	}
+}
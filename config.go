@@ -0,0 +1,276 @@
+package reflectflag
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Source decodes a configuration file into a flat map from flag name to
+// value. Every value must be either a string, which is passed to the flag's
+// Set method unchanged, or a []string, which is joined into the comma
+// separated form used by slice flags. Nested structures are flattened by
+// joining their keys with a ".", so a "host" key within a "db" section names
+// the flag "db.host". Sections therefore correspond to nested struct fields
+// only when the flags of the nested struct are named with a prefix, given by
+// its flagprefix tag or by AutoPrefix.
+type Source interface {
+	Decode(r io.Reader) (map[string]interface{}, error)
+}
+
+// The built in Sources. YAMLSource supports the commonly used subset of YAML:
+// block mappings, block and flow sequences of scalars, and plain or quoted
+// scalars. TOMLSource supports tables, dotted keys, inline tables, arrays of
+// scalars and single line strings, but not arrays of tables.
+var (
+	JSONSource Source = jsonSource{}
+	YAMLSource Source = yamlSource{}
+	TOMLSource Source = tomlSource{}
+)
+
+// sourcesByExt maps file extensions to the Source used by LoadFile.
+var sourcesByExt = map[string]Source{
+	".json": JSONSource,
+	".yaml": YAMLSource,
+	".yml":  YAMLSource,
+	".toml": TOMLSource,
+}
+
+// FileFormat specifies the Source LoadFile uses to decode files, rather than
+// choosing one from the file extension.
+func FileFormat(src Source) Option {
+	return fileFormatOpt{src}
+}
+
+type fileFormatOpt struct {
+	src Source
+}
+
+func (o fileFormatOpt) set(opts *options) {
+	opts.source = o.src
+}
+
+// LoadFile applies the configuration in the file at path to the flags in the
+// FlagSet. The format is chosen from the file extension (.json, .yaml, .yml or
+// .toml) unless the FileFormat Option is provided. See LoadSource for details.
+func LoadFile(flags *flag.FlagSet, path string, opts ...Option) error {
+	src := getOpts(opts...).source
+	if src == nil {
+		src = sourcesByExt[strings.ToLower(filepath.Ext(path))]
+		if src == nil {
+			return fmt.Errorf("%s: unable to determine config file format from extension", path)
+		}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := LoadSource(flags, src, f); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// LoadJSON reads a JSON object from r and applies it to the flags in the
+// FlagSet. An object provided for a map flag supplies its key=value pairs and
+// arrays are used for slice flags. See LoadSource for details.
+func LoadJSON(flags *flag.FlagSet, r io.Reader) error {
+	return LoadSource(flags, JSONSource, r)
+}
+
+// LoadSource decodes r with src and applies the result to the flags in the
+// FlagSet. Every value is applied with FlagSet.Set, exactly as if it were
+// provided on the command line. A name that is not a flag, but that starts
+// with the name of a map flag followed by a ".", provides an entry of that
+// map.
+//
+// Flags that have already been set, for example by FlagSet.Parse, are left
+// unchanged so that the command line takes precedence over the file. A typical
+// sequence is RegisterFlags, FlagSet.Parse, LoadFile and then LoadFromFlags.
+func LoadSource(flags *flag.FlagSet, src Source, r io.Reader) error {
+	values, err := src.Decode(r)
+	if err != nil {
+		return err
+	}
+	return applyConfig(flags, values)
+}
+
+type jsonSource struct{}
+
+func (jsonSource) Decode(r io.Reader) (map[string]interface{}, error) {
+	d := json.NewDecoder(r)
+	d.UseNumber()
+	var obj map[string]interface{}
+	if err := d.Decode(&obj); err != nil {
+		return nil, fmt.Errorf("invalid JSON config: %w", err)
+	}
+	values := map[string]interface{}{}
+	if err := flattenJSON(values, "", obj); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// flattenJSON adds the values of obj to values, naming nested values by
+// joining their keys with ".". Values are either strings or, for arrays,
+// string slices.
+func flattenJSON(values map[string]interface{}, prefix string, obj map[string]interface{}) error {
+	for k, v := range obj {
+		name := prefix + k
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if err := flattenJSON(values, name+".", v); err != nil {
+				return err
+			}
+		case []interface{}:
+			list := make([]string, 0, len(v))
+			for _, e := range v {
+				s, ok := jsonScalar(e)
+				if !ok {
+					return fmt.Errorf("unsupported value in array for %q: %v", name, e)
+				}
+				list = append(list, s)
+			}
+			values[name] = list
+		case nil:
+			// null leaves the flag unchanged
+		default:
+			s, ok := jsonScalar(v)
+			if !ok {
+				return fmt.Errorf("unsupported value for %q: %v", name, v)
+			}
+			values[name] = s
+		}
+	}
+	return nil
+}
+
+func jsonScalar(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		if v {
+			return "true", true
+		}
+		return "false", true
+	}
+	return "", false
+}
+
+// applyConfig sets the flags named in values, as described by LoadSource,
+// skipping flags that have already been set.
+func applyConfig(flags *flag.FlagSet, values map[string]interface{}) error {
+	set := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	mapEntries := map[string][]string{}
+	var mapNames []string
+	for _, name := range names {
+		var val string
+		switch v := values[name].(type) {
+		case string:
+			val = v
+		case []string:
+			val = joinCSV(v, flagSeparator(flags, name))
+		}
+		if flags.Lookup(name) == nil {
+			mapName, key := mapFlagFor(flags, name)
+			if mapName == "" {
+				return fmt.Errorf("unknown flag %q", name)
+			}
+			if _, ok := mapEntries[mapName]; !ok {
+				mapNames = append(mapNames, mapName)
+			}
+			mapEntries[mapName] = append(mapEntries[mapName], mapEntry(key, val))
+			continue
+		}
+		if set[name] {
+			continue
+		}
+		if err := flags.Set(name, val); err != nil {
+			return fmt.Errorf("invalid value %q for flag %q: %w", val, name, err)
+		}
+	}
+	for _, name := range mapNames {
+		if set[name] {
+			continue
+		}
+		val := joinCSV(mapEntries[name], flagSeparator(flags, name))
+		if err := flags.Set(name, val); err != nil {
+			return fmt.Errorf("invalid value %q for flag %q: %w", val, name, err)
+		}
+	}
+	return nil
+}
+
+// mapFlagFor returns the name of the map flag that name refers to an entry of,
+// along with the entry's key. It returns "" if there is no such flag.
+func mapFlagFor(flags *flag.FlagSet, name string) (string, string) {
+	for i := strings.LastIndex(name, "."); i > 0; i = strings.LastIndex(name[:i], ".") {
+		f := flags.Lookup(name[:i])
+		if f == nil {
+			continue
+		}
+		if _, ok := unwrapValue(f.Value).(*mapValue); ok {
+			return name[:i], name[i+1:]
+		}
+		return "", ""
+	}
+	return "", ""
+}
+
+// joinCSV returns the values in the comma separated form accepted by slice
+// and map flags, using sep as the separator if it is not 0.
+func joinCSV(values []string, sep rune) string {
+	if len(values) == 1 && values[0] == "" {
+		// An unquoted empty string is read back as no values at all.
+		return `""`
+	}
+	var buffer bytes.Buffer
+	w := csv.NewWriter(&buffer)
+	w.Comma = separator(sep)
+	w.Write(values)
+	w.Flush()
+	return strings.TrimSuffix(buffer.String(), "\n")
+}
+
+// separator returns sep, or ',' if sep is 0.
+func separator(sep rune) rune {
+	if sep == 0 {
+		return ','
+	}
+	return sep
+}
+
+// flagSeparator returns the separator of values of the named slice or map
+// flag, or 0 if it uses the default.
+func flagSeparator(flags *flag.FlagSet, name string) rune {
+	f := flags.Lookup(name)
+	if f == nil {
+		return 0
+	}
+	switch v := unwrapValue(f.Value).(type) {
+	case *sliceValue:
+		return v.sep
+	case *mapValue:
+		return v.sep
+	}
+	return 0
+}
@@ -0,0 +1,435 @@
+package reflectflag
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type configFileTest struct {
+	Name    string            `flag:"name"`
+	Port    int               `flag:"port"`
+	Debug   bool              `flag:"debug"`
+	Timeout time.Duration     `flag:"timeout"`
+	Hosts   []string          `flag:"hosts"`
+	Labels  map[string]string `flag:"labels"`
+	Ratio   *float64          `flag:"ratio"`
+	Host    string            `flag:"db.host"`
+}
+
+func TestLoadJSON(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		json    string
+		args    []string
+		want    configFileTest
+		wantErr string
+	}{
+		{
+			desc: "all values",
+			json: `{
+				"name": "svc",
+				"port": 8080,
+				"debug": true,
+				"timeout": "1m",
+				"hosts": ["a", "b,c"],
+				"labels": {"env": "prod", "team": "infra"},
+				"ratio": 0.5,
+				"db": {"host": "db.example.com"}
+			}`,
+			want: configFileTest{
+				Name:    "svc",
+				Port:    8080,
+				Debug:   true,
+				Timeout: time.Minute,
+				Hosts:   []string{"a", "b,c"},
+				Labels:  map[string]string{"env": "prod", "team": "infra"},
+				Ratio:   ptrTo(float64(0.5)).(*float64),
+				Host:    "db.example.com",
+			},
+		},
+		{
+			desc: "command line wins",
+			json: `{"name": "file", "port": 1, "hosts": "x,y", "db.host": "flat"}`,
+			args: []string{"--name=cmdline", "--labels=a=b"},
+			want: configFileTest{
+				Name:   "cmdline",
+				Port:   1,
+				Hosts:  []string{"x", "y"},
+				Labels: map[string]string{"a": "b"},
+				Ratio:  new(float64),
+				Host:   "flat",
+			},
+		},
+		{
+			desc:    "unknown flag",
+			json:    `{"db": {"port": 1}}`,
+			wantErr: `unknown flag "db.port"`,
+		},
+		{
+			desc:    "invalid value",
+			json:    `{"port": "eighty"}`,
+			wantErr: `invalid value "eighty" for flag "port": strconv.ParseInt: parsing "eighty": invalid syntax`,
+		},
+		{
+			desc:    "invalid json",
+			json:    `["port"]`,
+			wantErr: `invalid JSON config: json: cannot unmarshal array into Go value of type map[string]interface {}`,
+		},
+	} {
+		flags := flag.NewFlagSet("testflags", flag.ContinueOnError)
+		if err := RegisterFlags(flags, configFileTest{}); err != nil {
+			t.Fatalf("unexpected error from RegisterFlags: %v", err)
+		}
+		if err := flags.Parse(tc.args); err != nil {
+			t.Fatalf("unexpected error from Parse: %v", err)
+		}
+		err := LoadJSON(flags, strings.NewReader(tc.json))
+		if err != nil || tc.wantErr != "" {
+			if fmt.Sprintf("%v", err) != tc.wantErr {
+				t.Errorf("LoadJSON %q: unexpected error; got %v want %v", tc.desc, err, tc.wantErr)
+			}
+			continue
+		}
+		var got configFileTest
+		if err := LoadFromFlags(flags, &got); err != nil {
+			t.Fatalf("LoadJSON %q: unexpected error from LoadFromFlags: %v", tc.desc, err)
+		}
+		if !deepEqual(got, tc.want) {
+			t.Errorf("LoadJSON %q: unexpected result; got %#v want %#v", tc.desc, got, tc.want)
+		}
+	}
+}
+
+func TestLoadJSONNestedStruct(t *testing.T) {
+	type db struct {
+		Host string `flag:"host"`
+		Port int    `flag:"port"`
+	}
+	type config struct {
+		Name string `flag:"name"`
+		DB   db
+	}
+	type tagged struct {
+		Name string `flag:"name"`
+		DB   db     `flagprefix:"db."`
+	}
+	in := `{"name": "svc", "db": {"host": "db.example.com", "port": 5432}}`
+	want := db{Host: "db.example.com", Port: 5432}
+
+	flags := flag.NewFlagSet("testflags", flag.ContinueOnError)
+	if err := RegisterFlags(flags, config{}, AutoPrefix()); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadJSON(flags, strings.NewReader(in)); err != nil {
+		t.Fatalf("LoadJSON with AutoPrefix: unexpected error: %v", err)
+	}
+	var got config
+	if err := LoadFromFlags(flags, &got, AutoPrefix()); err != nil || got.Name != "svc" || got.DB != want {
+		t.Errorf("LoadJSON with AutoPrefix: got %+v, %v", got, err)
+	}
+
+	flags = flag.NewFlagSet("testflags", flag.ContinueOnError)
+	if err := RegisterFlags(flags, tagged{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadJSON(flags, strings.NewReader(in)); err != nil {
+		t.Fatalf("LoadJSON with flagprefix: unexpected error: %v", err)
+	}
+	var gotTagged tagged
+	if err := LoadFromFlags(flags, &gotTagged); err != nil || gotTagged.DB != want {
+		t.Errorf("LoadJSON with flagprefix: got %+v, %v", gotTagged, err)
+	}
+
+	// Without a prefix the flags of the nested struct are "host" and "port".
+	flags = flag.NewFlagSet("testflags", flag.ContinueOnError)
+	if err := RegisterFlags(flags, config{}); err != nil {
+		t.Fatal(err)
+	}
+	wantErr := `unknown flag "db.host"`
+	if err := LoadJSON(flags, strings.NewReader(in)); fmt.Sprintf("%v", err) != wantErr {
+		t.Errorf("LoadJSON without a prefix: unexpected error; got %v want %v", err, wantErr)
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"config.json": `{"name": "svc", "hosts": ["a", "b"], "db": {"host": "h"}}`,
+		"config.yaml": "name: svc\nhosts:\n  - a\n  - b\ndb:\n  host: h\n",
+		"config.yml":  "name: svc\nhosts: [a, b]\ndb.host: h\n",
+		"config.toml": "name = \"svc\"\nhosts = [\"a\", \"b\"]\n[db]\nhost = \"h\"\n",
+		"config.conf": "name = \"svc\"\nhosts = [\"a\", \"b\"]\ndb.host = \"h\"\n",
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := configFileTest{
+		Name:   "svc",
+		Hosts:  []string{"a", "b"},
+		Labels: map[string]string{},
+		Ratio:  new(float64),
+		Host:   "h",
+	}
+	for _, tc := range []struct {
+		file string
+		opts []Option
+	}{
+		{file: "config.json"},
+		{file: "config.yaml"},
+		{file: "config.yml"},
+		{file: "config.toml"},
+		{file: "config.conf", opts: []Option{FileFormat(TOMLSource)}},
+	} {
+		flags := flag.NewFlagSet("testflags", flag.ContinueOnError)
+		if err := RegisterFlags(flags, configFileTest{}); err != nil {
+			t.Fatalf("unexpected error from RegisterFlags: %v", err)
+		}
+		if err := LoadFile(flags, filepath.Join(dir, tc.file), tc.opts...); err != nil {
+			t.Errorf("LoadFile %s: unexpected error: %v", tc.file, err)
+			continue
+		}
+		var got configFileTest
+		if err := LoadFromFlags(flags, &got); err != nil {
+			t.Fatalf("LoadFile %s: unexpected error from LoadFromFlags: %v", tc.file, err)
+		}
+		if !deepEqual(got, want) {
+			t.Errorf("LoadFile %s: unexpected result; got %#v want %#v", tc.file, got, want)
+		}
+	}
+
+	flags := flag.NewFlagSet("testflags", flag.ContinueOnError)
+	if err := RegisterFlags(flags, configFileTest{}); err != nil {
+		t.Fatalf("unexpected error from RegisterFlags: %v", err)
+	}
+	path := filepath.Join(dir, "config.conf")
+	wantErr := path + ": unable to determine config file format from extension"
+	if err := LoadFile(flags, path); fmt.Sprintf("%v", err) != wantErr {
+		t.Errorf("unexpected error from LoadFile; got %v want %v", err, wantErr)
+	}
+	path = filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(path, []byte(`{"port": "x"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	wantErr = path + `: invalid value "x" for flag "port": strconv.ParseInt: parsing "x": invalid syntax`
+	if err := LoadFile(flags, path); fmt.Sprintf("%v", err) != wantErr {
+		t.Errorf("unexpected error from LoadFile; got %v want %v", err, wantErr)
+	}
+}
+
+func TestDecodeSources(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		src     Source
+		in      string
+		want    map[string]interface{}
+		wantErr string
+	}{
+		{
+			desc: "yaml",
+			src:  YAMLSource,
+			in: `---
+# comment
+name: svc   # trailing comment
+quoted: "a # not a comment\t"
+single: 'it''s'
+plain: it's here
+empty: ""
+nothing: ~
+port: 8080
+list:
+- a
+- "b,c"
+flow: [x, 'y z', "w"]
+emptylist: []
+db:
+  host: db.example.com
+  opts:
+    timeout: 1m
+"odd key": 1
+labels:
+  env: prod
+`,
+			want: map[string]interface{}{
+				"name":            "svc",
+				"quoted":          "a # not a comment\t",
+				"single":          "it's",
+				"plain":           "it's here",
+				"empty":           "",
+				"port":            "8080",
+				"list":            []string{"a", "b,c"},
+				"flow":            []string{"x", "y z", "w"},
+				"emptylist":       []string{},
+				"db.host":         "db.example.com",
+				"db.opts.timeout": "1m",
+				"odd key":         "1",
+				"labels.env":      "prod",
+			},
+		},
+		{
+			desc: "yaml escapes",
+			src:  YAMLSource,
+			in:   "a: \"\\/x\\x41\\u00e9\\_\"\n",
+			want: map[string]interface{}{"a": "/xA\u00e9\u00a0"},
+		},
+		{
+			desc:    "yaml bad escape",
+			src:     YAMLSource,
+			in:      "a: \"\\q\"\n",
+			wantErr: `line 1: invalid double quoted string "\q": invalid escape sequence \q`,
+		},
+		{
+			desc: "yaml empty sequence item",
+			src:  YAMLSource,
+			in:   "list:\n  -\n  - b\n",
+			want: map[string]interface{}{"list": []string{"", "b"}},
+		},
+		{
+			desc:    "yaml bad indentation",
+			src:     YAMLSource,
+			in:      "a: 1\n  b: 2\n",
+			wantErr: "line 2: unexpected indentation",
+		},
+		{
+			desc:    "yaml block scalar",
+			src:     YAMLSource,
+			in:      "a: |\n  text\n",
+			wantErr: "line 1: block scalars are not supported",
+		},
+		{
+			desc:    "yaml sequence of mappings",
+			src:     YAMLSource,
+			in:      "a:\n  - b: 1\n",
+			wantErr: "line 2: sequences of mappings are not supported",
+		},
+		{
+			desc:    "yaml duplicate key",
+			src:     YAMLSource,
+			in:      "a: 1\na: 2\n",
+			wantErr: `line 2: duplicate key "a"`,
+		},
+		{
+			desc: "toml",
+			src:  TOMLSource,
+			in: `# comment
+name = "svc" # trailing comment
+literal = 'C:\path'
+escaped = "tab\there"
+port = 8_080
+enabled = true
+ratio = 0.5
+when = 1979-05-27T07:32:00Z
+hosts = [
+  "a", # first
+  "b",
+]
+ports = [1, 2, 3]
+inline = { x = 1, y = "two" }
+a.b = "dotted"
+"quoted.key" = "q"
+
+[db]
+host = "db.example.com"
+
+[db."opts"]
+timeout = "1m"
+`,
+			want: map[string]interface{}{
+				"name":            "svc",
+				"literal":         `C:\path`,
+				"escaped":         "tab\there",
+				"port":            "8_080",
+				"enabled":         "true",
+				"ratio":           "0.5",
+				"when":            "1979-05-27T07:32:00Z",
+				"hosts":           []string{"a", "b"},
+				"ports":           []string{"1", "2", "3"},
+				"inline.x":        "1",
+				"inline.y":        "two",
+				"a.b":             "dotted",
+				"quoted.key":      "q",
+				"db.host":         "db.example.com",
+				"db.opts.timeout": "1m",
+			},
+		},
+		{
+			desc: "toml comments and escapes",
+			src:  TOMLSource,
+			in: `a = 1#c
+b = "x"#c
+c = 'y'# c
+d = "#\"\u00e9\U0001F600"
+e = '#'
+`,
+			want: map[string]interface{}{
+				"a": "1",
+				"b": "x",
+				"c": "y",
+				"d": "#\"\u00e9\U0001F600",
+				"e": "#",
+			},
+		},
+		{
+			desc:    "toml go escape",
+			src:     TOMLSource,
+			in:      `a = "\x41"`,
+			wantErr: `line 1: invalid string "\x41": invalid escape sequence \x`,
+		},
+		{
+			desc:    "toml unterminated literal string",
+			src:     TOMLSource,
+			in:      "a = '\n",
+			wantErr: "line 1: unterminated string '",
+		},
+		{
+			desc:    "toml unterminated quoted key",
+			src:     TOMLSource,
+			in:      "[']\n",
+			wantErr: "line 1: unterminated string '",
+		},
+		{
+			desc:    "toml array of tables",
+			src:     TOMLSource,
+			in:      "[[servers]]\nname = \"a\"\n",
+			wantErr: "line 1: arrays of tables are not supported",
+		},
+		{
+			desc:    "toml missing value",
+			src:     TOMLSource,
+			in:      "a = 1\nb =\n",
+			wantErr: "line 2: missing value",
+		},
+		{
+			desc:    "toml duplicate key",
+			src:     TOMLSource,
+			in:      "[a]\nb = 1\n[a]\nb = 2\n",
+			wantErr: `line 4: duplicate key "a.b"`,
+		},
+		{
+			desc: "json",
+			src:  JSONSource,
+			in:   `{"a": {"b": [1, true, "x"]}, "c": null, "d": 1.5}`,
+			want: map[string]interface{}{
+				"a.b": []string{"1", "true", "x"},
+				"d":   "1.5",
+			},
+		},
+	} {
+		got, err := tc.src.Decode(strings.NewReader(tc.in))
+		if err != nil || tc.wantErr != "" {
+			if fmt.Sprintf("%v", err) != tc.wantErr {
+				t.Errorf("Decode %q: unexpected error; got %v want %v", tc.desc, err, tc.wantErr)
+			}
+			continue
+		}
+		if !deepEqual(got, tc.want) {
+			t.Errorf("Decode %q: unexpected result; got %#v want %#v", tc.desc, got, tc.want)
+		}
+	}
+}
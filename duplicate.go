@@ -0,0 +1,86 @@
+package reflectflag
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+)
+
+// DuplicateFlagError is returned by RegisterFlags and Bind, wrapped in a
+// *FieldError, when a flag name is used by more than one field of the struct,
+// or is already defined in the FlagSet.
+type DuplicateFlagError struct {
+	// Flag is the conflicting flag name.
+	Flag string
+	// Type is the struct type and Field the dotted path of the field that
+	// could not be registered.
+	Type  reflect.Type
+	Field string
+	// OtherType and OtherField identify the field that already uses the name.
+	// They are unset if the flag was defined in the FlagSet before
+	// registration started.
+	OtherType  reflect.Type
+	OtherField string
+}
+
+func (e *DuplicateFlagError) Error() string {
+	if e.OtherType == nil {
+		return fmt.Sprintf("flag %q is already defined", e.Flag)
+	}
+	return fmt.Sprintf("flag %q is also used by field %s.%s", e.Flag, e.OtherType, e.OtherField)
+}
+
+// DuplicatePolicy determines how RegisterFlags and Bind handle a flag name
+// that is already in use.
+type DuplicatePolicy int
+
+const (
+	// DuplicateError makes registration fail with a *DuplicateFlagError
+	// before any flags are registered. It is the default.
+	DuplicateError DuplicatePolicy = iota
+	// DuplicateSkip keeps the existing flag and does not register the field.
+	// LoadFromFlags loads the field from the existing flag, which must be of
+	// a compatible type.
+	DuplicateSkip
+	// DuplicateOverride replaces the value and usage of the existing flag
+	// with those of the field.
+	DuplicateOverride
+)
+
+// OnDuplicate sets the policy applied when a flag name is already in use.
+func OnDuplicate(policy DuplicatePolicy) Option {
+	return duplicateOpt(policy)
+}
+
+type duplicateOpt DuplicatePolicy
+
+func (o duplicateOpt) set(opts *options) {
+	opts.duplicates = DuplicatePolicy(o)
+}
+
+// checkDuplicates returns a *FieldError wrapping a *DuplicateFlagError for the
+// first field of p whose flag name, or short name, is already defined in the
+// FlagSet or used by an earlier field.
+func checkDuplicates(flags *flag.FlagSet, p *structPlan) error {
+	seen := map[string]*fieldPlan{}
+	for i := range p.fields {
+		fp := &p.fields[i]
+		for _, name := range []string{fp.name, fp.spec.short} {
+			if name == "" {
+				continue
+			}
+			if other, ok := seen[name]; ok {
+				return duplicateError(p, fp, &DuplicateFlagError{Flag: name, Type: p.typ, Field: fp.path, OtherType: p.typ, OtherField: other.path})
+			}
+			if flags.Lookup(name) != nil {
+				return duplicateError(p, fp, &DuplicateFlagError{Flag: name, Type: p.typ, Field: fp.path})
+			}
+			seen[name] = fp
+		}
+	}
+	return nil
+}
+
+func duplicateError(p *structPlan, fp *fieldPlan, err *DuplicateFlagError) error {
+	return &FieldError{Op: "register", Type: p.typ, Field: fp.path, Flag: fp.name, Err: err}
+}
@@ -0,0 +1,80 @@
+package reflectflag
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestDuplicateFlags(t *testing.T) {
+	type server struct {
+		Host string `flag:"host"`
+	}
+	type config struct {
+		DB    server
+		Cache server
+	}
+	type other struct {
+		Host string `flag:"host" default:"other" help:"Other host"`
+		Port int    `flag:"port"`
+	}
+
+	flags := flag.NewFlagSet("testflags", flag.ContinueOnError)
+	err := RegisterFlags(flags, config{})
+	var de *DuplicateFlagError
+	if !errors.As(err, &de) {
+		t.Fatalf("expected a *DuplicateFlagError from RegisterFlags; got %v", err)
+	}
+	want := &DuplicateFlagError{
+		Flag:       "host",
+		Type:       reflect.TypeOf(config{}),
+		Field:      "Cache.Host",
+		OtherType:  reflect.TypeOf(config{}),
+		OtherField: "DB.Host",
+	}
+	if !reflect.DeepEqual(de, want) {
+		t.Errorf("unexpected error from RegisterFlags; got %#v want %#v", de, want)
+	}
+	wantErr := `unable to register flag for field reflectflag.config.Cache.Host: flag "host" is also used by field reflectflag.config.DB.Host`
+	if fmt.Sprintf("%v", err) != wantErr {
+		t.Errorf("unexpected error from RegisterFlags; got %v want %v", err, wantErr)
+	}
+	if flags.Lookup("host") != nil {
+		t.Errorf("RegisterFlags registered flags before detecting the conflict")
+	}
+
+	flags.String("port", "1", "existing")
+	wantErr = `unable to register flag for field reflectflag.other.Port: flag "port" is already defined`
+	if err := RegisterFlags(flags, other{}); fmt.Sprintf("%v", err) != wantErr {
+		t.Errorf("unexpected error from RegisterFlags; got %v want %v", err, wantErr)
+	}
+
+	// Skipping keeps the first flag, so both fields load the same value.
+	flags = flag.NewFlagSet("testflags", flag.ContinueOnError)
+	if err := RegisterFlags(flags, config{}, OnDuplicate(DuplicateSkip)); err != nil {
+		t.Fatalf("unexpected error from RegisterFlags: %v", err)
+	}
+	if err := flags.Parse([]string{"--host=h"}); err != nil {
+		t.Fatal(err)
+	}
+	var cfg config
+	if err := LoadFromFlags(flags, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DB.Host != "h" || cfg.Cache.Host != "h" {
+		t.Errorf("unexpected output from LoadFromFlags: %#v", cfg)
+	}
+
+	// Overriding replaces the value and usage of the existing flag.
+	flags = flag.NewFlagSet("testflags", flag.ContinueOnError)
+	flags.String("host", "existing", "existing")
+	if err := RegisterFlags(flags, other{}, OnDuplicate(DuplicateOverride)); err != nil {
+		t.Fatalf("unexpected error from RegisterFlags: %v", err)
+	}
+	f := flags.Lookup("host")
+	if f.Value.String() != "other" || f.DefValue != "other" || f.Usage != "Other host" {
+		t.Errorf("flag was not overridden: %#v", f)
+	}
+}
@@ -0,0 +1,45 @@
+package reflectflag
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// Sentinel errors that may be tested for with errors.Is.
+var (
+	// ErrNotStruct is returned when a value that must be a struct, or a
+	// pointer to one, is not.
+	ErrNotStruct = errors.New("not a struct type")
+	// ErrNotStructPointer is returned when a value that must be a pointer to
+	// a struct is not.
+	ErrNotStructPointer = errors.New("not a pointer to a struct")
+	// ErrNoFactory is returned when a field has a type for which no
+	// flag.Getter can be created.
+	ErrNoFactory = errors.New("no flag factory registered")
+	// ErrFlagNotRegistered is returned by LoadFromFlags when the FlagSet has
+	// no flag for a field.
+	ErrFlagNotRegistered = errors.New("flag not registered")
+)
+
+// FieldError is returned when an operation fails for a single struct field.
+type FieldError struct {
+	// Op is the operation that failed, such as "register" or "load".
+	Op string
+	// Type is the struct type and Field the dotted path of Go field names to
+	// the field.
+	Type  reflect.Type
+	Field string
+	// Flag is the name of the field's flag, if it is known.
+	Flag string
+	// Err is the cause of the failure.
+	Err error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("unable to %s flag for field %s.%s: %v", e.Op, e.Type, e.Field, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
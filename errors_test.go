@@ -0,0 +1,73 @@
+package reflectflag
+
+import (
+	"errors"
+	"flag"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestErrors(t *testing.T) {
+	type unsupported struct{ X int }
+	type config struct {
+		Port int `flag:"port" default:"eighty"`
+	}
+	type noFactory struct {
+		U unsupported `flag:"u"`
+	}
+	newFlags := func() *flag.FlagSet {
+		return flag.NewFlagSet("testflags", flag.ContinueOnError)
+	}
+
+	var fe *FieldError
+	err := RegisterFlags(newFlags(), config{})
+	if !errors.As(err, &fe) {
+		t.Fatalf("expected a *FieldError from RegisterFlags; got %v", err)
+	}
+	if fe.Op != "register" || fe.Type != reflect.TypeOf(config{}) || fe.Field != "Port" || fe.Flag != "port" {
+		t.Errorf("unexpected FieldError: %#v", fe)
+	}
+	if !errors.Is(err, strconv.ErrSyntax) {
+		t.Errorf("expected the cause of %v to be strconv.ErrSyntax", err)
+	}
+
+	err = RegisterFlags(newFlags(), noFactory{})
+	if !errors.Is(err, ErrNoFactory) || !errors.As(err, &fe) || fe.Field != "U" {
+		t.Errorf("expected a *FieldError wrapping ErrNoFactory; got %v", err)
+	}
+	if _, err := Explain(noFactory{}); !errors.Is(err, ErrNoFactory) {
+		t.Errorf("expected ErrNoFactory from Explain; got %v", err)
+	}
+
+	if err := RegisterFlags(newFlags(), 1); !errors.Is(err, ErrNotStruct) {
+		t.Errorf("expected ErrNotStruct from RegisterFlags; got %v", err)
+	}
+	if err := CheckRequired(newFlags(), "x"); !errors.Is(err, ErrNotStruct) {
+		t.Errorf("expected ErrNotStruct from CheckRequired; got %v", err)
+	}
+	if err := Bind(newFlags(), config{}); !errors.Is(err, ErrNotStructPointer) {
+		t.Errorf("expected ErrNotStructPointer from Bind; got %v", err)
+	}
+	if err := LoadFromFlags(newFlags(), config{}); !errors.Is(err, ErrNotStructPointer) {
+		t.Errorf("expected ErrNotStructPointer from LoadFromFlags; got %v", err)
+	}
+
+	// Errors from config files keep their causes.
+	for _, src := range []Source{YAMLSource, TOMLSource} {
+		in := "a: \"\\uZZZZ\"\n"
+		if src == TOMLSource {
+			in = "a = \"\\uZZZZ\"\n"
+		}
+		err := LoadSource(newFlags(), src, strings.NewReader(in))
+		if !errors.Is(err, strconv.ErrSyntax) {
+			t.Errorf("expected the cause of %v to be strconv.ErrSyntax", err)
+		}
+	}
+
+	err = LoadFromFlags(newFlags(), &config{})
+	if !errors.Is(err, ErrFlagNotRegistered) || !errors.As(err, &fe) || fe.Op != "load" || fe.Flag != "port" {
+		t.Errorf("expected a *FieldError wrapping ErrFlagNotRegistered; got %v", err)
+	}
+}
@@ -0,0 +1,36 @@
+package reflectflag_test
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ggriffiniii/reflectflag"
+)
+
+type ServerOptions struct {
+	Addr    string        `flag:"addr" default:":8080" help:"Address to listen on"`
+	Timeout time.Duration `flag:"timeout" default:"30s" help:"Request timeout"`
+	Hosts   []string      `flag:"hosts" help:"Backend hosts"`
+}
+
+// Example_parse demonstrates building, parsing and loading flags in one step.
+func Example_parse() {
+	opts, args, err := reflectflag.ParseArgs[ServerOptions]([]string{
+		"--timeout=1m",
+		"--hosts=a.example.com,b.example.com",
+		"status",
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("Addr: %v\n", opts.Addr)
+	fmt.Printf("Timeout: %v\n", opts.Timeout)
+	fmt.Printf("Hosts: %v\n", opts.Hosts)
+	fmt.Printf("Args: %v\n", args)
+	// Output:
+	// Addr: :8080
+	// Timeout: 1m0s
+	// Hosts: [a.example.com b.example.com]
+	// Args: [status]
+}
@@ -0,0 +1,116 @@
+package reflectflag
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FlagFile registers a flag with the given name, in addition to the flags of
+// the struct, whose value is the path of a file of flags. Each line of the file
+// holds a single flag in the form "--name=value" (or "-name=value"); a boolean
+// flag may be given as just "--name". Blank lines and lines starting with "#"
+// are ignored. A file may include other files with the same flag; relative
+// paths are resolved from the directory of the including file.
+//
+// The file is read when the flag is encountered during FlagSet.Parse, so flags
+// that follow it on the command line override the values in the file and flags
+// that precede it are overridden by them.
+func FlagFile(name string) Option {
+	return flagFileOpt(name)
+}
+
+type flagFileOpt string
+
+func (o flagFileOpt) set(opts *options) {
+	opts.flagFile = string(o)
+}
+
+// registerFlagFile registers the flagfile flag if it was requested and is not
+// already present in the FlagSet.
+func registerFlagFile(flags *flag.FlagSet, opts options) {
+	if opts.flagFile == "" || flags.Lookup(opts.flagFile) != nil {
+		return
+	}
+	flags.Var(&flagFileValue{flags: flags, name: opts.flagFile}, opts.flagFile, "Read flags from the named file, one --name=value per line")
+}
+
+// flagFileValue is the flag.Value of the flagfile flag.
+type flagFileValue struct {
+	flags  *flag.FlagSet
+	name   string
+	files  []string // files read so far
+	active []string // files currently being read, to detect cycles
+}
+
+func (f *flagFileValue) Set(path string) error {
+	return f.load(path, "")
+}
+
+func (f *flagFileValue) Get() interface{} {
+	return append([]string(nil), f.files...)
+}
+
+func (f *flagFileValue) String() string {
+	return strings.Join(f.files, ",")
+}
+
+// load applies the flags in the file at path, which is resolved relative to
+// dir if it is not absolute.
+func (f *flagFileValue) load(path, dir string) error {
+	if dir != "" && !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	for _, a := range f.active {
+		if a == abs {
+			return fmt.Errorf("include cycle: %s -> %s", strings.Join(f.active, " -> "), abs)
+		}
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	f.active = append(f.active, abs)
+	defer func() { f.active = f.active[:len(f.active)-1] }()
+	f.files = append(f.files, path)
+
+	scanner := bufio.NewScanner(file)
+	for num := 1; scanner.Scan(); num++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !strings.HasPrefix(line, "-") {
+			return fmt.Errorf("%s:%d: expected --name=value, got %q", path, num, line)
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(line, "-"), "=")
+		if name == f.name {
+			if err := f.load(value, filepath.Dir(path)); err != nil {
+				return fmt.Errorf("%s:%d: %w", path, num, err)
+			}
+			continue
+		}
+		flg := f.flags.Lookup(name)
+		if flg == nil {
+			return fmt.Errorf("%s:%d: flag provided but not defined: -%s", path, num, name)
+		}
+		if !hasValue {
+			if bf, ok := flg.Value.(interface{ IsBoolFlag() bool }); !ok || !bf.IsBoolFlag() {
+				return fmt.Errorf("%s:%d: flag needs an argument: -%s", path, num, name)
+			}
+			value = "true"
+		}
+		if err := f.flags.Set(name, value); err != nil {
+			return fmt.Errorf("%s:%d: invalid value %q for flag -%s: %w", path, num, value, name, err)
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,79 @@
+package reflectflag
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFlagFile(t *testing.T) {
+	type config struct {
+		Name    string   `flag:"name"`
+		Port    int      `flag:"port"`
+		Verbose bool     `flag:"verbose"`
+		Hosts   []string `flag:"hosts"`
+	}
+	dir := t.TempDir()
+	write := func(name, contents string) string {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	main := write("main.flags", `
+# service flags
+--name=from_file
+-port=80
+--verbose
+--flagfile=sub/hosts.flags
+`)
+	write("sub/hosts.flags", "--hosts=a,b\n--port=81\n")
+	cycle := write("cycle.flags", "--flagfile=sub/cycle.flags\n")
+	write("sub/cycle.flags", "--flagfile=../cycle.flags\n")
+	bad := write("bad.flags", "--name=ok\n\n--port=eighty\n")
+	unknown := write("unknown.flags", "--nope=1\n")
+
+	parse := func(args ...string) (config, error) {
+		flags := flag.NewFlagSet("testflags", flag.ContinueOnError)
+		flags.SetOutput(io.Discard)
+		if err := RegisterFlags(flags, config{}, FlagFile("flagfile")); err != nil {
+			t.Fatalf("unexpected error from RegisterFlags: %v", err)
+		}
+		var cfg config
+		if err := flags.Parse(args); err != nil {
+			return cfg, err
+		}
+		err := LoadFromFlags(flags, &cfg)
+		return cfg, err
+	}
+
+	got, err := parse("--port=1", "--flagfile="+main, "--name=override")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := config{Name: "override", Port: 81, Verbose: true, Hosts: []string{"a", "b"}}
+	if !deepEqual(got, want) {
+		t.Errorf("unexpected result; got %#v want %#v", got, want)
+	}
+
+	for _, tc := range []struct {
+		path    string
+		wantErr string
+	}{
+		{path: cycle, wantErr: cycle + ":1: " + filepath.Join(dir, "sub/cycle.flags") + ":1: include cycle: "},
+		{path: bad, wantErr: bad + `:3: invalid value "eighty" for flag -port: strconv.ParseInt`},
+		{path: unknown, wantErr: unknown + ":1: flag provided but not defined: -nope"},
+	} {
+		_, err := parse("--flagfile=" + tc.path)
+		if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("unexpected error for %s; got %v want %v", tc.path, err, tc.wantErr)
+		}
+	}
+}
@@ -1,11 +1,13 @@
 package reflectflag
 
 import (
-	"bytes"
+	"encoding"
 	"encoding/csv"
 	"flag"
 	"fmt"
 	"io"
+	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -195,13 +197,96 @@ func (d *durationValue) Get() interface{} { return time.Duration(*d) }
 
 func (d *durationValue) String() string { return (*time.Duration)(d).String() }
 
+var (
+	flagValueType       = reflect.TypeOf((*flag.Value)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// newFieldFlagValue returns a flag.Getter for a value whose pointer implements
+// flag.Value. A copy of v is made so the field it came from is not modified.
+// The copy is used directly if it implements flag.Getter and Get returns a
+// value of the same type, rather than, say, its underlying int.
+func newFieldFlagValue(v reflect.Value) flag.Getter {
+	cp := reflect.New(v.Type())
+	cp.Elem().Set(v)
+	if g, ok := cp.Interface().(flag.Getter); ok && reflect.TypeOf(g.Get()) == v.Type() {
+		return g
+	}
+	return &valueGetter{wrappedValue: wrappedValue{cp.Interface().(flag.Value)}, p: cp}
+}
+
+// valueGetter implements flag.Getter for a flag.Value. Get returns a copy of
+// the value itself.
+type valueGetter struct {
+	wrappedValue
+	p reflect.Value // pointer to the value
+}
+
+func (g *valueGetter) Get() interface{} {
+	cp := reflect.New(g.p.Type().Elem())
+	cp.Elem().Set(g.p.Elem())
+	return cp.Elem().Interface()
+}
+
+// textValue adapts a type whose pointer implements encoding.TextUnmarshaler. If
+// the type also implements encoding.TextMarshaler it is used to format the
+// value and to make deep copies in Get.
+type textValue struct {
+	p reflect.Value // pointer to the current value
+}
+
+func newTextValue(v reflect.Value) flag.Getter {
+	src := reflect.New(v.Type())
+	src.Elem().Set(v)
+	return &textValue{p: copyText(src)}
+}
+
+func (t *textValue) Set(val string) error {
+	return t.p.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(val))
+}
+
+func (t *textValue) Get() interface{} {
+	return copyText(t.p).Elem().Interface()
+}
+
+// copyText returns a pointer to a deep copy of the value pointed to by p, made
+// by marshaling and unmarshaling it as text. If the type does not implement
+// encoding.TextMarshaler, or the round trip fails, a shallow copy is returned.
+func copyText(p reflect.Value) reflect.Value {
+	cp := reflect.New(p.Type().Elem())
+	if m, ok := p.Interface().(encoding.TextMarshaler); ok {
+		if text, err := m.MarshalText(); err == nil {
+			if err := cp.Interface().(encoding.TextUnmarshaler).UnmarshalText(text); err == nil {
+				return cp
+			}
+		}
+	}
+	cp.Elem().Set(p.Elem())
+	return cp
+}
+
+func (t *textValue) String() string {
+	if !t.p.IsValid() {
+		// The flag package calls String on the zero value.
+		return ""
+	}
+	if m, ok := t.p.Interface().(encoding.TextMarshaler); ok {
+		if text, err := m.MarshalText(); err == nil {
+			return string(text)
+		}
+	}
+	return fmt.Sprintf("%v", t.p.Elem().Interface())
+}
+
 type sliceValue struct {
 	f      flag.Getter
 	values []string
+	sep    rune // separator of values, or 0 for ','
 }
 
 func (sv *sliceValue) Set(val string) error {
 	r := csv.NewReader(strings.NewReader(val))
+	r.Comma = separator(sv.sep)
 	records, err := r.Read()
 	if err != nil {
 		if err != io.EOF {
@@ -230,9 +315,175 @@ func (sv *sliceValue) Get() interface{} {
 }
 
 func (sv *sliceValue) String() string {
-	var buffer bytes.Buffer
-	w := csv.NewWriter(&buffer)
-	w.Write(sv.values)
-	w.Flush()
-	return buffer.String()
+	return joinCSV(sv.values, sv.sep)
+}
+
+// mapValue holds a set of key=value pairs. The value may be provided as a comma
+// separated list of pairs and the flag may be repeated. The first time the flag
+// is set its initial contents are replaced; subsequent values are added to the
+// map. A key containing "=" or starting with a double quote is double quoted,
+// with any double quotes within it doubled.
+type mapValue struct {
+	f      flag.Getter
+	values map[string]string
+	set    bool
+	sep    rune // separator of entries, or 0 for ','
+}
+
+func (mv *mapValue) Set(val string) error {
+	r := csv.NewReader(strings.NewReader(val))
+	r.Comma = separator(mv.sep)
+	records, err := r.Read()
+	if err != nil && err != io.EOF {
+		return err
+	}
+	entries := map[string]string{}
+	for _, rec := range records {
+		k, v, ok := splitMapEntry(rec)
+		if !ok {
+			return fmt.Errorf("invalid map entry %q: expected key=value", rec)
+		}
+		if err := mv.f.Set(v); err != nil {
+			return err
+		}
+		entries[k] = v
+	}
+	if !mv.set {
+		mv.values = map[string]string{}
+		mv.set = true
+	}
+	for k, v := range entries {
+		mv.values[k] = v
+	}
+	return nil
+}
+
+func (mv *mapValue) Get() interface{} {
+	ret := map[string]interface{}{}
+	for k, v := range mv.values {
+		mv.f.Set(v)
+		ret[k] = mv.f.Get()
+	}
+	return ret
+}
+
+func (mv *mapValue) String() string {
+	keys := make([]string, 0, len(mv.values))
+	for k := range mv.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	entries := make([]string, 0, len(keys))
+	for _, k := range keys {
+		entries = append(entries, mapEntry(k, mv.values[k]))
+	}
+	return joinCSV(entries, mv.sep)
+}
+
+// mapEntry returns the key=value entry of a mapValue for key and val.
+func mapEntry(key, val string) string {
+	if strings.Contains(key, "=") || strings.HasPrefix(key, `"`) {
+		key = `"` + strings.ReplaceAll(key, `"`, `""`) + `"`
+	}
+	return key + "=" + val
+}
+
+// splitMapEntry splits an entry produced by mapEntry into its key and value.
+func splitMapEntry(entry string) (key, val string, ok bool) {
+	if !strings.HasPrefix(entry, `"`) {
+		return strings.Cut(entry, "=")
+	}
+	var b strings.Builder
+	for i := 1; i < len(entry); i++ {
+		if entry[i] != '"' {
+			b.WriteByte(entry[i])
+			continue
+		}
+		if i+1 < len(entry) && entry[i+1] == '"' {
+			b.WriteByte('"')
+			i++
+			continue
+		}
+		if !strings.HasPrefix(entry[i+1:], "=") {
+			return "", "", false
+		}
+		return b.String(), entry[i+2:], true
+	}
+	return "", "", false
+}
+
+// resetMerge makes the next Set of a map flag replace its current contents
+// rather than add to them. Slice flags are always replaced by Set.
+func resetMerge(v flag.Value) {
+	if mv, ok := unwrapValue(v).(*mapValue); ok {
+		mv.set = false
+	}
+}
+
+// wrappedValue is embedded by the types that wrap the flag.Value of a flag to
+// forward String and IsBoolFlag to it.
+type wrappedValue struct {
+	flag.Value
+}
+
+func (w wrappedValue) String() string {
+	if w.Value == nil {
+		// The flag package calls String on the zero value.
+		return ""
+	}
+	return w.Value.String()
+}
+
+func (w wrappedValue) IsBoolFlag() bool {
+	bf, ok := w.Value.(interface{ IsBoolFlag() bool })
+	return ok && bf.IsBoolFlag()
+}
+
+// getter returns the wrapped value of a wrapper of a flag.Getter.
+func (w wrappedValue) getter() flag.Getter {
+	return w.Value.(flag.Getter)
+}
+
+// boundValue is the flag.Value registered by Bind. It writes the value of the
+// underlying flag.Getter into the struct field dst every time it is set.
+type boundValue struct {
+	wrappedValue
+	dst  reflect.Value
+	name string
+}
+
+func (b *boundValue) Set(val string) error {
+	if err := b.Value.Set(val); err != nil {
+		return err
+	}
+	return setFieldFromFlag(b.dst, b.name, b.getter())
+}
+
+func (b *boundValue) Get() interface{} {
+	return b.getter().Get()
+}
+
+// unwrapValue returns the flag.Value underlying any wrapper added when the flag
+// was registered.
+func unwrapValue(v flag.Value) flag.Value {
+	for {
+		next, ok := unwrapOnce(v)
+		if !ok {
+			return v
+		}
+		v = next
+	}
+}
+
+// unwrapOnce removes a single wrapper from v, reporting whether v was wrapped.
+func unwrapOnce(v flag.Value) (flag.Value, bool) {
+	switch w := v.(type) {
+	case *boundValue:
+		return w.Value, true
+	case *trackedValue:
+		return w.Value, true
+	case *specValue:
+		return w.Value, true
+	}
+	return v, false
 }
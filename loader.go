@@ -0,0 +1,136 @@
+package reflectflag
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+)
+
+// Loader populates a struct from layered configuration sources, recording
+// which source supplied the final value of every flag. Sources are applied in
+// order of increasing precedence:
+//
+//	struct    the value of the field in the struct passed to Load
+//	default   the default tag of the field
+//	file      the config files in Files, later files overriding earlier ones
+//	env       the environment, as configured by EnvPrefix and env tags
+//	cmdline   the command line arguments passed to Load
+//
+// A Loader may be reused; every call to Load starts afresh.
+type Loader struct {
+	// Files lists config files to load, in any format understood by
+	// LoadFile.
+	Files []string
+	// Options are used to register and load the flags of the struct.
+	Options []Option
+
+	flags      *flag.FlagSet
+	provenance map[string]string
+	current    string // source of the values being applied
+}
+
+// Load populates the struct pointed to by s from the sources of the Loader
+// and the command line arguments args, which should not include the program
+// name. Errors from parsing args are returned as a *ParseError.
+func (l *Loader) Load(s interface{}, args []string) error {
+	v := reflect.ValueOf(s)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("unable to load flags for %q: %w", typeString(s), ErrNotStructPointer)
+	}
+	o := getOpts(l.Options...)
+	p, err := getPlan(v.Elem().Type(), o, "register")
+	if err != nil {
+		return err
+	}
+	l.flags = newFlagSet()
+	l.provenance = map[string]string{}
+	// The environment is applied below, after any config files.
+	o.skipEnv = true
+	if o.deprecatedOut == nil {
+		o.deprecatedOut = os.Stderr
+	}
+	registerFlagFile(l.flags, o)
+	if err := registerPlan(l.flags, v.Elem(), p, o); err != nil {
+		return err
+	}
+	for i := range p.fields {
+		fp := &p.fields[i]
+		f := l.flags.Lookup(fp.name)
+		if _, ok := f.Value.(*trackedValue); !ok {
+			f.Value = &trackedValue{wrappedValue: wrappedValue{f.Value}, loader: l, name: fp.name}
+		}
+		if fp.hasDef {
+			l.provenance[fp.name] = "default"
+		} else {
+			l.provenance[fp.name] = "struct"
+		}
+	}
+
+	// LoadFile only applies values for flags that have not been set, so
+	// files are applied last to first.
+	for i := len(l.Files) - 1; i >= 0; i-- {
+		l.nextLayer(p)
+		l.current = "file:" + l.Files[i]
+		if err := LoadFile(l.flags, l.Files[i], l.Options...); err != nil {
+			return err
+		}
+	}
+	l.nextLayer(p)
+	for i := range p.fields {
+		fp := &p.fields[i]
+		if fp.env == "" {
+			continue
+		}
+		if val, ok := os.LookupEnv(fp.env); ok {
+			l.current = "env:" + fp.env
+			if err := l.flags.Set(fp.name, val); err != nil {
+				return &FieldError{Op: "load", Type: p.typ, Field: fp.path, Flag: fp.name, Err: fmt.Errorf("invalid value %q for environment variable %s: %w", val, fp.env, err)}
+			}
+		}
+	}
+	l.nextLayer(p)
+	l.current = "cmdline"
+	if err := parseArgs(l.flags, args, o); err != nil {
+		return err
+	}
+	return LoadFromFlags(l.flags, s, l.Options...)
+}
+
+// nextLayer prepares the flags of p for the next source, so that a map flag set
+// by the source replaces the value from lower sources rather than merging
+// with it.
+func (l *Loader) nextLayer(p *structPlan) {
+	for i := range p.fields {
+		resetMerge(l.flags.Lookup(p.fields[i].name).Value)
+	}
+}
+
+// Provenance returns the source that supplied the value of the named flag in
+// the last call to Load: "struct", "default", "file:<path>", "env:<VAR>" or
+// "cmdline". It returns "" if the flag is unknown.
+func (l *Loader) Provenance(name string) string {
+	return l.provenance[name]
+}
+
+// FlagSet returns the FlagSet created by the last call to Load, or nil if Load
+// has not been called.
+func (l *Loader) FlagSet() *flag.FlagSet {
+	return l.flags
+}
+
+// trackedValue is the flag.Value registered by Loader. It records the source of
+// the value every time it is set.
+type trackedValue struct {
+	wrappedValue
+	loader *Loader
+	name   string
+}
+
+func (t *trackedValue) Set(val string) error {
+	if err := t.Value.Set(val); err != nil {
+		return err
+	}
+	t.loader.provenance[t.name] = t.loader.current
+	return nil
+}
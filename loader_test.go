@@ -0,0 +1,133 @@
+package reflectflag
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoader(t *testing.T) {
+	type config struct {
+		DBURL   string            `flag:"db-url,required"`
+		Port    int               `flag:"port" default:"80"`
+		Name    string            `flag:"name"`
+		Level   string            `flag:"level" default:"info"`
+		Hosts   []string          `flag:"hosts"`
+		Labels  map[string]string `flag:"labels"`
+		Verbose bool              `flag:"verbose"`
+	}
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base.json")
+	override := filepath.Join(dir, "override.yaml")
+	if err := os.WriteFile(base, []byte(`{"db-url": "postgres://base", "port": 81, "hosts": ["a", "b"]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(override, []byte("port: 82\nlabels:\n  env: prod\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("MYAPP_DB_URL", "postgres://env")
+
+	l := &Loader{
+		Files:   []string{base, override},
+		Options: []Option{EnvPrefix("MYAPP_")},
+	}
+	cfg := config{Name: "from-struct"}
+	if err := l.Load(&cfg, []string{"--verbose", "--level=debug"}); err != nil {
+		t.Fatalf("unexpected error from Load: %v", err)
+	}
+	want := config{
+		DBURL:   "postgres://env",
+		Port:    82,
+		Name:    "from-struct",
+		Level:   "debug",
+		Hosts:   []string{"a", "b"},
+		Labels:  map[string]string{"env": "prod"},
+		Verbose: true,
+	}
+	if !deepEqual(cfg, want) {
+		t.Errorf("unexpected output from Load; got %#v want %#v", cfg, want)
+	}
+	for name, want := range map[string]string{
+		"db-url":  "env:MYAPP_DB_URL",
+		"port":    "file:" + override,
+		"name":    "struct",
+		"level":   "cmdline",
+		"hosts":   "file:" + base,
+		"labels":  "file:" + override,
+		"verbose": "cmdline",
+		"unknown": "",
+	} {
+		if got := l.Provenance(name); got != want {
+			t.Errorf("Provenance(%q) = %q, want %q", name, got, want)
+		}
+	}
+
+	// Reloading starts afresh.
+	os.Unsetenv("MYAPP_DB_URL")
+	l.Files = nil
+	var cfg2 config
+	if err := l.Load(&cfg2, []string{"--db-url=x"}); err != nil {
+		t.Fatalf("unexpected error from Load: %v", err)
+	}
+	if got := l.Provenance("port"); got != "default" {
+		t.Errorf("Provenance(\"port\") = %q, want \"default\"", got)
+	}
+	if cfg2.Port != 80 || l.FlagSet().Lookup("db-url").Value.String() != "x" {
+		t.Errorf("unexpected output from Load; got %#v", cfg2)
+	}
+
+	wantErr := "missing required flags: -db-url"
+	if err := l.Load(&config{}, nil); fmt.Sprintf("%v", err) != wantErr {
+		t.Errorf("unexpected error from Load; got %v want %v", err, wantErr)
+	}
+	t.Setenv("MYAPP_PORT", "eighty")
+	if err := l.Load(&config{}, nil); err == nil {
+		t.Errorf("expected an error from Load with an invalid environment value")
+	}
+	if err := l.Load(config{}, nil); err == nil {
+		t.Errorf("expected an error from Load with a non-pointer")
+	}
+	if err := l.Load(nil, nil); err == nil {
+		t.Errorf("expected an error from Load with nil")
+	}
+}
+
+func TestLoaderMapLayers(t *testing.T) {
+	type config struct {
+		Labels map[string]string `flag:"labels"`
+	}
+	file := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(file, []byte(`{"labels": {"a": "1"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		env     string
+		args    []string
+		want    map[string]string
+		wantSrc string
+	}{
+		{want: map[string]string{"a": "1"}, wantSrc: "file:" + file},
+		{args: []string{"--labels=b=2"}, want: map[string]string{"b": "2"}, wantSrc: "cmdline"},
+		{args: []string{"--labels=b=2", "--labels=c=3"}, want: map[string]string{"b": "2", "c": "3"}, wantSrc: "cmdline"},
+		{env: "c=3", want: map[string]string{"c": "3"}, wantSrc: "env:ZZ_LABELS"},
+		{env: "c=3", args: []string{"--labels=b=2"}, want: map[string]string{"b": "2"}, wantSrc: "cmdline"},
+	} {
+		if tc.env != "" {
+			t.Setenv("ZZ_LABELS", tc.env)
+		} else {
+			os.Unsetenv("ZZ_LABELS")
+		}
+		l := &Loader{Files: []string{file}, Options: []Option{EnvPrefix("ZZ_")}}
+		var cfg config
+		if err := l.Load(&cfg, tc.args); err != nil {
+			t.Fatalf("Load(env=%q, %q): unexpected error: %v", tc.env, tc.args, err)
+		}
+		if !deepEqual(cfg.Labels, tc.want) {
+			t.Errorf("Load(env=%q, %q): got %v want %v", tc.env, tc.args, cfg.Labels, tc.want)
+		}
+		if got := l.Provenance("labels"); got != tc.wantSrc {
+			t.Errorf("Load(env=%q, %q): Provenance(\"labels\") = %q, want %q", tc.env, tc.args, got, tc.wantSrc)
+		}
+	}
+}
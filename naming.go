@@ -0,0 +1,99 @@
+package reflectflag
+
+import (
+	"reflect"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A Namer converts the name of a Go struct field into a flag name.
+type Namer func(field string) string
+
+// AutoName registers a flag for every exported field without a flag tag whose
+// type is supported, named by applying n to the field name. A tag with an
+// empty name, such as `flag:",required"`, also uses the generated name, while
+// an explicit name in the tag takes precedence. A field tagged `flag:"-"` is
+// never registered. Untagged struct fields whose type is not itself supported
+// are descended into as usual.
+//
+// Registration plans are only cached for the built in Namers KebabCase,
+// SnakeCase and LowerCamel.
+func AutoName(n Namer) Option {
+	return autoNameOpt{n}
+}
+
+type autoNameOpt struct {
+	n Namer
+}
+
+func (o autoNameOpt) set(opts *options) {
+	opts.autoName = o.n
+}
+
+// KebabCase converts a field name such as "MaxHTTPRetries" into
+// "max-http-retries".
+func KebabCase(field string) string {
+	return strings.Join(fieldWords(field), "-")
+}
+
+// SnakeCase converts a field name such as "MaxHTTPRetries" into
+// "max_http_retries".
+func SnakeCase(field string) string {
+	return strings.Join(fieldWords(field), "_")
+}
+
+// LowerCamel converts a field name such as "MaxHTTPRetries" into
+// "maxHttpRetries".
+func LowerCamel(field string) string {
+	words := fieldWords(field)
+	for i := 1; i < len(words); i++ {
+		r, n := utf8.DecodeRuneInString(words[i])
+		words[i] = string(unicode.ToUpper(r)) + words[i][n:]
+	}
+	return strings.Join(words, "")
+}
+
+// fieldWords splits a Go identifier into lower case words. A word starts at an
+// upper case letter that follows a lower case letter or digit, or that is
+// followed by a lower case letter after a run of upper case letters, so that
+// acronyms such as "HTTP" form a single word. Underscores also separate words.
+func fieldWords(field string) []string {
+	runes := []rune(field)
+	var words []string
+	start := 0
+	for i := 0; i <= len(runes); i++ {
+		split := i == len(runes) || runes[i] == '_'
+		if !split && i > start && unicode.IsUpper(runes[i]) {
+			prev := runes[i-1]
+			split = unicode.IsLower(prev) || unicode.IsDigit(prev) ||
+				(unicode.IsUpper(prev) && i+1 < len(runes) && unicode.IsLower(runes[i+1]))
+		}
+		if !split {
+			continue
+		}
+		if i > start {
+			words = append(words, strings.ToLower(string(runes[start:i])))
+		}
+		start = i
+		if i < len(runes) && runes[i] == '_' {
+			start++
+		}
+	}
+	return words
+}
+
+// namerID identifies the built in Namers so that plans using them can be
+// cached. Other Namers may be closures, which cannot be told apart.
+func namerID(n Namer) (uintptr, bool) {
+	if n == nil {
+		return 0, true
+	}
+	p := reflect.ValueOf(n).Pointer()
+	for _, b := range []Namer{KebabCase, SnakeCase, LowerCamel} {
+		if reflect.ValueOf(b).Pointer() == p {
+			return p, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,93 @@
+package reflectflag
+
+import (
+	"flag"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNamers(t *testing.T) {
+	tests := []struct {
+		field, kebab, snake, camel string
+	}{
+		{"Port", "port", "port", "port"},
+		{"MaxRetries", "max-retries", "max_retries", "maxRetries"},
+		{"DBURL", "dburl", "dburl", "dburl"},
+		{"HTTPServer", "http-server", "http_server", "httpServer"},
+		{"UserID", "user-id", "user_id", "userId"},
+		{"Port2Host", "port2-host", "port2_host", "port2Host"},
+		{"Snake_Case", "snake-case", "snake_case", "snakeCase"},
+		{"MaxÉcart", "max-écart", "max_écart", "maxÉcart"},
+	}
+	for _, test := range tests {
+		if got := KebabCase(test.field); got != test.kebab {
+			t.Errorf("KebabCase(%q) = %q, want %q", test.field, got, test.kebab)
+		}
+		if got := SnakeCase(test.field); got != test.snake {
+			t.Errorf("SnakeCase(%q) = %q, want %q", test.field, got, test.snake)
+		}
+		if got := LowerCamel(test.field); got != test.camel {
+			t.Errorf("LowerCamel(%q) = %q, want %q", test.field, got, test.camel)
+		}
+	}
+}
+
+func TestAutoName(t *testing.T) {
+	type server struct {
+		ListenAddr string
+	}
+	type config struct {
+		DBURL      string `flag:",required"`
+		MaxRetries int
+		Timeout    time.Duration `flag:"deadline"`
+		Hosts      []string
+		Started    time.Time
+		Secret     string `flag:"-"`
+		Callback   func()
+		Server     server
+		internal   int
+	}
+	names := func(opts ...Option) []string {
+		flags := flag.NewFlagSet("testflags", flag.ContinueOnError)
+		if err := RegisterFlags(flags, config{}, opts...); err != nil {
+			t.Fatalf("unexpected error from RegisterFlags: %v", err)
+		}
+		var ret []string
+		flags.VisitAll(func(f *flag.Flag) {
+			ret = append(ret, f.Name)
+		})
+		return ret
+	}
+	want := []string{"dburl", "deadline", "hosts", "max-retries", "server.listen-addr", "started"}
+	if got := names(AutoName(KebabCase), AutoPrefix()); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected flags with AutoName(KebabCase); got %q want %q", got, want)
+	}
+	want = []string{"DBURL!", "Hosts!", "ListenAddr!", "MaxRetries!", "Started!", "deadline"}
+	custom := func(field string) string { return field + "!" }
+	if got := names(AutoName(custom)); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected flags with a custom Namer; got %q want %q", got, want)
+	}
+
+	// Without AutoName a tag with an empty name is an error.
+	err := RegisterFlags(flag.NewFlagSet("testflags", flag.ContinueOnError), config{})
+	if err == nil || !strings.Contains(err.Error(), `missing flag name in tag ",required"`) {
+		t.Errorf("unexpected error from RegisterFlags without AutoName: %v", err)
+	}
+
+	flags := flag.NewFlagSet("testflags", flag.ContinueOnError)
+	if err := RegisterFlags(flags, config{}, AutoName(SnakeCase)); err != nil {
+		t.Fatal(err)
+	}
+	if err := flags.Parse([]string{"--dburl=x", "--max_retries=3", "--listen_addr=:80"}); err != nil {
+		t.Fatal(err)
+	}
+	var cfg config
+	if err := LoadFromFlags(flags, &cfg, AutoName(SnakeCase)); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DBURL != "x" || cfg.MaxRetries != 3 || cfg.Server.ListenAddr != ":80" {
+		t.Errorf("unexpected output from LoadFromFlags: %#v", cfg)
+	}
+}
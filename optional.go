@@ -0,0 +1,140 @@
+package reflectflag
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Optional holds a value of type T that may or may not be set. An Optional
+// struct field is registered as a flag of type T, and LoadFromFlags leaves it
+// empty unless the flag was given a value: on the command line, by a default
+// tag, by the environment, by a config file, or by the struct passed to
+// RegisterFlags. T may be any type supported as a struct field other than a
+// slice or map.
+type Optional[T any] struct {
+	value T
+	set   bool
+}
+
+// Some returns an Optional holding v.
+func Some[T any](v T) Optional[T] {
+	return Optional[T]{value: v, set: true}
+}
+
+// Get returns the value held by o and whether it is set.
+func (o Optional[T]) Get() (T, bool) {
+	return o.value, o.set
+}
+
+// IsSet reports whether o holds a value.
+func (o Optional[T]) IsSet() bool {
+	return o.set
+}
+
+// Or returns the value held by o, or def if o is empty.
+func (o Optional[T]) Or(def T) T {
+	if !o.set {
+		return def
+	}
+	return o.value
+}
+
+func (o Optional[T]) String() string {
+	if !o.set {
+		return "<unset>"
+	}
+	return fmt.Sprint(o.value)
+}
+
+func (Optional[T]) optionalElem() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+func (o Optional[T]) optionalValue() (reflect.Value, bool) {
+	return reflect.ValueOf(&o.value).Elem(), o.set
+}
+
+func (o *Optional[T]) setOptional(v reflect.Value) {
+	o.value = v.Interface().(T)
+	o.set = true
+}
+
+// optional is implemented by every *Optional[T].
+type optional interface {
+	optionalElem() reflect.Type
+	optionalValue() (reflect.Value, bool)
+	setOptional(v reflect.Value)
+}
+
+var optionalType = reflect.TypeOf((*optional)(nil)).Elem()
+
+// optionalElem returns the type held by typ if it is an Optional.
+func optionalElem(typ reflect.Type) (reflect.Type, bool) {
+	if typ.Kind() != reflect.Struct || !reflect.PtrTo(typ).Implements(optionalType) {
+		return nil, false
+	}
+	return reflect.New(typ).Interface().(optional).optionalElem(), true
+}
+
+// NilIfUnset makes RegisterFlags and Bind track whether each pointer field's
+// flag is given a value, so that LoadFromFlags leaves the field nil rather than
+// pointing it at the zero value. A flag is given a value on the command line, by
+// a default tag, by the environment, by a config file, or by a non-nil field in
+// the struct passed to RegisterFlags. Optional fields behave this way without
+// the Option.
+func NilIfUnset() Option {
+	return nilIfUnsetOpt{}
+}
+
+type nilIfUnsetOpt struct{}
+
+func (nilIfUnsetOpt) set(opts *options) {
+	opts.nilIfUnset = true
+}
+
+// optionalValue wraps the flag.Getter of an Optional field, or of a pointer
+// field with NilIfUnset, recording whether it has been given a value.
+type optionalValue struct {
+	wrappedValue
+	set bool
+}
+
+func (o *optionalValue) Set(val string) error {
+	if err := o.Value.Set(val); err != nil {
+		return err
+	}
+	o.set = true
+	return nil
+}
+
+func (o *optionalValue) Get() interface{} {
+	return o.getter().Get()
+}
+
+func (o *optionalValue) String() string {
+	if !o.set {
+		// An unset value has no default to show.
+		return ""
+	}
+	return o.wrappedValue.String()
+}
+
+// setFieldFromOptional sets the Optional or pointer field v from ov.
+func setFieldFromOptional(v reflect.Value, flagName string, ov *optionalValue) error {
+	if !ov.set {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	elem, ok := optionalElem(v.Type())
+	if !ok {
+		return setFieldFromFlag(v, flagName, ov.getter())
+	}
+	newV, err := convertValueTo(reflect.ValueOf(ov.Get()), elem)
+	if err != nil {
+		return err
+	}
+	o := reflect.New(v.Type())
+	o.Interface().(optional).setOptional(newV)
+	v.Set(o.Elem())
+	return nil
+}
@@ -0,0 +1,107 @@
+package reflectflag
+
+import (
+	"bytes"
+	"flag"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOptional(t *testing.T) {
+	type config struct {
+		Port    Optional[int]           `flag:"port"`
+		Name    Optional[string]        `flag:"name" default:"x"`
+		Timeout Optional[time.Duration] `flag:"timeout"`
+		Verbose Optional[bool]          `flag:"verbose"`
+		Level   Optional[*int]          `flag:"level"`
+		Count   *int                    `flag:"count"`
+		Limit   *int                    `flag:"limit"`
+	}
+	register := func(s config, opts ...Option) *flag.FlagSet {
+		flags := flag.NewFlagSet("testflags", flag.ContinueOnError)
+		if err := RegisterFlags(flags, s, opts...); err != nil {
+			t.Fatalf("unexpected error from RegisterFlags: %v", err)
+		}
+		return flags
+	}
+	limit := 7
+	flags := register(config{Timeout: Some(time.Second), Limit: &limit}, NilIfUnset())
+	if err := flags.Parse([]string{"--port=0", "--verbose", "--level=3"}); err != nil {
+		t.Fatalf("unexpected error from Parse: %v", err)
+	}
+	var cfg config
+	if err := LoadFromFlags(flags, &cfg, NilIfUnset()); err != nil {
+		t.Fatalf("unexpected error from LoadFromFlags: %v", err)
+	}
+	if v, ok := cfg.Port.Get(); !ok || v != 0 {
+		t.Errorf("Port = %v, want Some(0)", cfg.Port)
+	}
+	if cfg.Name.Or("") != "x" || cfg.Timeout.Or(0) != time.Second || !cfg.Verbose.Or(false) {
+		t.Errorf("unexpected Optional values: %v %v %v", cfg.Name, cfg.Timeout, cfg.Verbose)
+	}
+	if l := cfg.Level.Or(nil); l == nil || *l != 3 {
+		t.Errorf("Level = %v, want Some(3)", cfg.Level)
+	}
+	if cfg.Count != nil {
+		t.Errorf("Count = %v, want nil", *cfg.Count)
+	}
+	if cfg.Limit == nil || *cfg.Limit != 7 {
+		t.Errorf("Limit = %v, want 7", cfg.Limit)
+	}
+
+	// Without any values every Optional is empty.
+	flags = register(config{})
+	if err := flags.Parse(nil); err != nil {
+		t.Fatal(err)
+	}
+	cfg = config{Port: Some(1)}
+	if err := LoadFromFlags(flags, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port.IsSet() || cfg.Timeout.IsSet() || cfg.Verbose.IsSet() || !cfg.Name.IsSet() {
+		t.Errorf("unexpected Optional values: %v %v %v %v", cfg.Port, cfg.Timeout, cfg.Verbose, cfg.Name)
+	}
+	if cfg.Count == nil {
+		t.Errorf("Count = nil without NilIfUnset")
+	}
+
+	// Unset Optionals have no default, so none is shown in the usage.
+	var usage bytes.Buffer
+	flags.SetOutput(&usage)
+	flags.PrintDefaults()
+	if strings.Contains(usage.String(), "(default 0)") || !strings.Contains(usage.String(), "(default x)") {
+		t.Errorf("unexpected defaults in usage:\n%s", usage.String())
+	}
+
+	// Bind only fills in Optionals that are given a value.
+	var bound config
+	flags = flag.NewFlagSet("testflags", flag.ContinueOnError)
+	if err := Bind(flags, &bound, NilIfUnset()); err != nil {
+		t.Fatal(err)
+	}
+	if err := flags.Parse([]string{"--timeout=1m", "--count=2"}); err != nil {
+		t.Fatal(err)
+	}
+	if bound.Timeout.Or(0) != time.Minute || bound.Port.IsSet() || bound.Count == nil || *bound.Count != 2 || bound.Limit != nil {
+		t.Errorf("unexpected bound values: %+v", bound)
+	}
+
+	args, err := ToArgs(config{Port: Some(0), Name: Some("y"), Count: &limit}, NilIfUnset())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"--port=0", "--name=y", "--count=7"}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("unexpected output from ToArgs; got %q want %q", args, want)
+	}
+
+	res, err := Explain(config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res[0].Type != reflect.TypeOf(0) {
+		t.Errorf("Explain resolved Optional[int] as %v", res[0].Type)
+	}
+}
@@ -0,0 +1,95 @@
+package reflectflag
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ParseError is returned by Parse when the command line arguments cannot be
+// parsed. If the arguments requested help (-h or -help) Err is flag.ErrHelp.
+type ParseError struct {
+	// Err is the error returned by FlagSet.Parse.
+	Err error
+	// Usage holds the defaults of every flag as printed by PrintDefaults.
+	Usage string
+}
+
+func (e *ParseError) Error() string {
+	return "unable to parse flags: " + e.Err.Error()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Parse creates a FlagSet with the flags associated with the struct type T,
+// parses args and returns a T populated with the result. args should not
+// include the program name. Nothing is printed on failure; errors from parsing
+// are returned as a *ParseError, although deprecated flags are warned about on
+// os.Stderr unless DeprecationOutput is given. With the ResponseFiles Option,
+// "@path" arguments are expanded before parsing. Non-flag arguments following
+// the flags are an error; use ParseArgs to accept them.
+func Parse[T any](args []string, opts ...Option) (T, error) {
+	cfg, rest, err := ParseArgs[T](args, opts...)
+	if err != nil {
+		return cfg, err
+	}
+	if len(rest) > 0 {
+		return cfg, &ParseError{Err: fmt.Errorf("unexpected arguments %q", rest)}
+	}
+	return cfg, nil
+}
+
+// ParseArgs is like Parse, but also returns the non-flag arguments that follow
+// the flags.
+func ParseArgs[T any](args []string, opts ...Option) (T, []string, error) {
+	var cfg T
+	opts = append([]Option{DeprecationOutput(os.Stderr)}, opts...)
+	flags := newFlagSet()
+	if err := RegisterFlags(flags, cfg, opts...); err != nil {
+		return cfg, nil, err
+	}
+	if err := parseArgs(flags, args, getOpts(opts...)); err != nil {
+		return cfg, nil, err
+	}
+	if err := LoadFromFlags(flags, &cfg, opts...); err != nil {
+		return cfg, nil, err
+	}
+	return cfg, flags.Args(), nil
+}
+
+// newFlagSet returns a FlagSet named after the program that reports errors
+// without printing anything.
+func newFlagSet() *flag.FlagSet {
+	name := ""
+	if len(os.Args) > 0 {
+		name = filepath.Base(os.Args[0])
+	}
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	flags.Usage = func() {}
+	return flags
+}
+
+// parseArgs expands response files in args if requested and parses the result,
+// returning any error as a *ParseError.
+func parseArgs(flags *flag.FlagSet, args []string, opts options) error {
+	if opts.responseFiles {
+		expanded, err := ExpandResponseFiles(args)
+		if err != nil {
+			return &ParseError{Err: err}
+		}
+		args = expanded
+	}
+	if err := flags.Parse(args); err != nil {
+		var usage bytes.Buffer
+		flags.SetOutput(&usage)
+		PrintDefaults(flags)
+		return &ParseError{Err: err, Usage: usage.String()}
+	}
+	return nil
+}
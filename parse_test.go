@@ -0,0 +1,89 @@
+package reflectflag
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	type config struct {
+		Name    string        `flag:"name,required" help:"Name to greet"`
+		Timeout time.Duration `flag:"timeout" default:"5s"`
+		Ports   []int         `flag:"ports"`
+	}
+	got, rest, err := ParseArgs[config]([]string{"--name=world", "--ports=80,443", "in.txt", "--out"})
+	if err != nil {
+		t.Fatalf("unexpected error from Parse: %v", err)
+	}
+	want := config{
+		Name:    "world",
+		Timeout: 5 * time.Second,
+		Ports:   []int{80, 443},
+	}
+	if !deepEqual(got, want) {
+		t.Errorf("unexpected output from Parse; got %#v want %#v", got, want)
+	}
+	if len(rest) != 2 || rest[0] != "in.txt" || rest[1] != "--out" {
+		t.Errorf("unexpected remaining arguments from ParseArgs; got %q", rest)
+	}
+	if got, err := Parse[config]([]string{"--name=world", "--ports=80,443"}); err != nil || !deepEqual(got, want) {
+		t.Errorf("unexpected output from Parse; got %#v, %v want %#v", got, err, want)
+	}
+	wantErr := `unable to parse flags: unexpected arguments ["in.txt" "--out"]`
+	if _, err := Parse[config]([]string{"--name=world", "in.txt", "--out"}); fmt.Sprintf("%v", err) != wantErr {
+		t.Errorf("unexpected error from Parse; got %v want %v", err, wantErr)
+	}
+
+	_, err = Parse[config]([]string{"--name=world", "--timeout=soon"})
+	var pe *ParseError
+	if !errors.As(err, &pe) {
+		t.Fatalf("expected a *ParseError from Parse; got %v", err)
+	}
+	wantErr = `unable to parse flags: invalid value "soon" for flag -timeout: time: invalid duration "soon"`
+	if fmt.Sprintf("%v", err) != wantErr {
+		t.Errorf("unexpected error from Parse; got %v want %v", err, wantErr)
+	}
+
+	_, err = Parse[config]([]string{"-h"})
+	if !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("expected flag.ErrHelp from Parse; got %v", err)
+	}
+	if !errors.As(err, &pe) || !strings.Contains(pe.Usage, "Name to greet") {
+		t.Errorf("expected usage text in ParseError; got %#v", pe)
+	}
+
+	wantErr = "missing required flags: -name"
+	if _, err := Parse[config](nil); fmt.Sprintf("%v", err) != wantErr {
+		t.Errorf("unexpected error from Parse; got %v want %v", err, wantErr)
+	}
+	if _, err := Parse[int](nil); err == nil {
+		t.Errorf("expected an error from Parse with a non-struct type")
+	}
+}
+
+func TestParseDeprecated(t *testing.T) {
+	type config struct {
+		Old int `flag:"old,deprecated=use -new instead"`
+		New int `flag:"new"`
+	}
+	var out bytes.Buffer
+	got, err := Parse[config]([]string{"--old=3"}, DeprecationOutput(&out))
+	if err != nil {
+		t.Fatalf("unexpected error from Parse: %v", err)
+	}
+	if got.Old != 3 {
+		t.Errorf("unexpected output from Parse; got %#v", got)
+	}
+	if want := "flag -old is deprecated: use -new instead\n"; out.String() != want {
+		t.Errorf("unexpected deprecation warning from Parse; got %q want %q", out.String(), want)
+	}
+	out.Reset()
+	if _, err := Parse[config]([]string{"--new=3"}, DeprecationOutput(&out)); err != nil || out.Len() != 0 {
+		t.Errorf("unexpected deprecation warning from Parse; got %q, %v", out.String(), err)
+	}
+}
@@ -0,0 +1,322 @@
+package reflectflag
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// structPlan records everything about a struct type that is needed to
+// register and load its flags, so that reflection over the struct's fields
+// and type resolution only happen once per struct type and set of options.
+type structPlan struct {
+	typ      reflect.Type
+	fields   []fieldPlan
+	required []string // names of required flags
+}
+
+// fieldPlan describes the flag associated with a single struct field.
+type fieldPlan struct {
+	index     []int  // index sequence for reflect.Value.FieldByIndex
+	path      string // dotted path of Go field names
+	field     reflect.StructField
+	spec      fieldSpec
+	name      string // flag name including any prefix
+	usage     string
+	def       string
+	hasDef    bool
+	env       string       // environment variable providing the value, if any
+	container reflect.Kind // reflect.Slice or reflect.Map, otherwise reflect.Invalid
+	optional  bool         // the field is an Optional of the resolved type
+	res       resolution   // resolution of the field type, or its element type
+}
+
+// newGetter returns a flag.Getter initialized with the current value of the
+// field v.
+func (fp *fieldPlan) newGetter(v reflect.Value, opts options) flag.Getter {
+	switch fp.container {
+	case reflect.Slice:
+		v = derefFully(v)
+		sv := &sliceValue{f: fp.res.newGetter(reflect.Zero(v.Type().Elem()), opts), sep: fp.spec.sep}
+		for i := 0; i < v.Len(); i++ {
+			sv.values = append(sv.values, fp.res.newGetter(v.Index(i), opts).String())
+		}
+		return sv
+	case reflect.Map:
+		v = derefFully(v)
+		mv := &mapValue{
+			f:      fp.res.newGetter(reflect.Zero(v.Type().Elem()), opts),
+			values: map[string]string{},
+			sep:    fp.spec.sep,
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			mv.values[iter.Key().String()] = fp.res.newGetter(iter.Value(), opts).String()
+		}
+		return mv
+	}
+	if fp.optional {
+		o := reflect.New(v.Type())
+		o.Elem().Set(v)
+		val, set := o.Interface().(optional).optionalValue()
+		return &optionalValue{wrappedValue: wrappedValue{fp.res.newGetter(val, opts)}, set: set}
+	}
+	if opts.nilIfUnset && v.Kind() == reflect.Ptr {
+		return &optionalValue{wrappedValue: wrappedValue{fp.res.newGetter(v, opts)}, set: !v.IsNil()}
+	}
+	return fp.res.newGetter(v, opts)
+}
+
+// planKey identifies the options a structPlan was compiled with. Registered
+// types are compared separately since they are held in a slice. FlagPrefix and
+// EnvPrefix are not part of the key; they are applied to the cached plan by
+// withPrefixes, so that plans are shared by any number of prefixes.
+type planKey struct {
+	typ            reflect.Type
+	tagName        string
+	helpTagName    string
+	defaultTagName string
+	envTagName     string
+	prefixTagName  string
+	autoPrefix     bool
+	autoName       uintptr
+}
+
+type cachedPlan struct {
+	ftypes []reflect.Type
+	plan   *structPlan
+}
+
+// maxCachedPlans bounds the number of plans held by planCache. When it is
+// reached the cache is cleared, so that plans in use are simply compiled again
+// rather than any one of them being evicted at random.
+const maxCachedPlans = 1024
+
+var planCache = struct {
+	sync.RWMutex
+	m map[planKey][]cachedPlan
+	n int // number of cached plans
+}{m: map[planKey][]cachedPlan{}}
+
+// getPlan returns the structPlan for the struct type typ, compiling and
+// caching it if necessary. op describes the operation being performed and is
+// included in any error.
+func getPlan(typ reflect.Type, opts options, op string) (*structPlan, error) {
+	key := planKey{
+		typ:            typ,
+		tagName:        opts.tagName,
+		helpTagName:    opts.helpTagName,
+		defaultTagName: opts.defaultTagName,
+		envTagName:     opts.envTagName,
+		prefixTagName:  opts.prefixTagName,
+		autoPrefix:     opts.autoPrefix,
+	}
+	var cacheable bool
+	key.autoName, cacheable = namerID(opts.autoName)
+	if !cacheable {
+		p, err := compilePlan(typ, opts, op)
+		if err != nil {
+			return nil, err
+		}
+		return p.withPrefixes(opts), nil
+	}
+	planCache.RLock()
+	for _, c := range planCache.m[key] {
+		if sameTypes(c.ftypes, opts.ftypes) {
+			planCache.RUnlock()
+			return c.plan.withPrefixes(opts), nil
+		}
+	}
+	planCache.RUnlock()
+
+	p, err := compilePlan(typ, opts, op)
+	if err != nil {
+		return nil, err
+	}
+	ftypes := make([]reflect.Type, len(opts.ftypes))
+	for i, ft := range opts.ftypes {
+		ftypes[i] = ft.typ
+	}
+	planCache.Lock()
+	if planCache.n >= maxCachedPlans {
+		planCache.m = map[planKey][]cachedPlan{}
+		planCache.n = 0
+	}
+	planCache.m[key] = append(planCache.m[key], cachedPlan{ftypes: ftypes, plan: p})
+	planCache.n++
+	planCache.Unlock()
+	return p.withPrefixes(opts), nil
+}
+
+// withPrefixes returns p with the FlagPrefix added to every flag name and
+// environment variable names derived with EnvPrefix. p itself is returned if
+// neither Option is in effect.
+func (p *structPlan) withPrefixes(opts options) *structPlan {
+	if opts.flagPrefix == "" && !opts.envEnabled {
+		return p
+	}
+	cp := &structPlan{typ: p.typ, fields: make([]fieldPlan, len(p.fields))}
+	for i, fp := range p.fields {
+		fp.name = opts.flagPrefix + fp.name
+		if fp.env == "" && opts.envEnabled {
+			fp.env = envNameForFlag(fp.name, opts)
+		}
+		cp.fields[i] = fp
+		if fp.spec.required {
+			cp.required = append(cp.required, fp.name)
+		}
+	}
+	return cp
+}
+
+func sameTypes(types []reflect.Type, ftypes []flagTypeOpt) bool {
+	if len(types) != len(ftypes) {
+		return false
+	}
+	for i := range types {
+		if types[i] != ftypes[i].typ {
+			return false
+		}
+	}
+	return true
+}
+
+// compilePlan builds the structPlan for the struct type typ. Flag names do not
+// include the FlagPrefix, and only environment variables named by tags are
+// recorded; see withPrefixes.
+func compilePlan(typ reflect.Type, opts options, op string) (*structPlan, error) {
+	p := &structPlan{typ: typ}
+	err := walkFlagFields(typ, opts, func(sf reflect.StructField, index []int, path, prefix, tag string) error {
+		spec, err := parseFlagTag(tag)
+		if err != nil {
+			return &FieldError{Op: op, Type: typ, Field: path, Err: err}
+		}
+		if spec.name == "" {
+			if opts.autoName == nil {
+				return &FieldError{Op: op, Type: typ, Field: path, Err: fmt.Errorf("missing flag name in tag %q", tag)}
+			}
+			spec.name = opts.autoName(sf.Name)
+		}
+		fp := fieldPlan{
+			index: index,
+			path:  path,
+			field: sf,
+			spec:  spec,
+			name:  prefix + spec.name,
+			usage: usageForField(sf, opts),
+		}
+		fp.def, fp.hasDef = sf.Tag.Lookup(opts.defaultTagName)
+		fp.env = envNameForField(sf, spec, opts)
+		if err := fp.resolve(opts); err != nil {
+			return &FieldError{Op: op, Type: typ, Field: path, Flag: opts.flagPrefix + fp.name, Err: err}
+		}
+		if spec.sep != 0 && fp.container == reflect.Invalid {
+			return &FieldError{Op: op, Type: typ, Field: path, Flag: opts.flagPrefix + fp.name, Err: fmt.Errorf("option \"sep\" requires a slice or map field")}
+		}
+		p.fields = append(p.fields, fp)
+		if spec.required {
+			p.required = append(p.required, fp.name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// resolve determines how the flag.Getter for the field is created. Slices,
+// maps with string keys and Optionals are supported for any element type that
+// has a registered flag factory.
+func (fp *fieldPlan) resolve(opts options) error {
+	typ := fp.field.Type
+	if elem, ok := optionalElem(typ); ok {
+		res, ok := resolveType(elem, opts)
+		if !ok {
+			return fmt.Errorf("%w for %v", ErrNoFactory, elem)
+		}
+		fp.optional = true
+		fp.res = res
+		return nil
+	}
+	if res, ok := resolveType(typ, opts); ok {
+		fp.res = res
+		return nil
+	}
+	base := baseType(typ)
+	switch base.Kind() {
+	case reflect.Slice:
+	case reflect.Map:
+		if base.Key().Kind() != reflect.String {
+			return fmt.Errorf("unsupported map key type %v: only string keys are supported", base.Key())
+		}
+	default:
+		return fmt.Errorf("%w for %v", ErrNoFactory, typ)
+	}
+	res, ok := resolveType(base.Elem(), opts)
+	if !ok {
+		return fmt.Errorf("%w for %v", ErrNoFactory, base.Elem())
+	}
+	fp.container = base.Kind()
+	fp.res = res
+	return nil
+}
+
+// walkFlagFields invokes fn for every exported field of the struct type typ
+// that has a flag tag, or with AutoName every untagged field of a supported
+// type, descending into other untagged struct fields. index is the
+// index sequence of the field within typ, path is the dotted path of field
+// names from typ to the field and prefix is the flag name prefix contributed
+// by the enclosing struct fields.
+func walkFlagFields(typ reflect.Type, opts options, fn func(sf reflect.StructField, index []int, path, prefix, tag string) error) error {
+	return walkFlagFieldsPath(typ, opts, nil, "", "", fn)
+}
+
+func walkFlagFieldsPath(typ reflect.Type, opts options, index []int, pathPrefix, namePrefix string, fn func(sf reflect.StructField, index []int, path, prefix, tag string) error) error {
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		if sf.PkgPath != "" {
+			// skip non-exported fields
+			continue
+		}
+		fieldIndex := append(append([]int(nil), index...), i)
+		path := pathPrefix + sf.Name
+		tag := sf.Tag.Get(opts.tagName)
+		if tag == "-" {
+			continue
+		}
+		if tag == "" && opts.autoName != nil && (&fieldPlan{field: sf}).resolve(opts) == nil {
+			if err := fn(sf, fieldIndex, path, namePrefix, tag); err != nil {
+				return err
+			}
+			continue
+		}
+		if tag == "" {
+			if sf.Type.Kind() == reflect.Struct {
+				if err := walkFlagFieldsPath(sf.Type, opts, fieldIndex, path+".", namePrefix+structPrefix(sf, opts), fn); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if err := fn(sf, fieldIndex, path, namePrefix, tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// structPrefix returns the prefix the nested struct field sf adds to the names
+// of its flags: the value of its prefix tag, or with AutoPrefix the lower case
+// field name followed by a "." unless the field is embedded.
+func structPrefix(sf reflect.StructField, opts options) string {
+	if prefix, ok := sf.Tag.Lookup(opts.prefixTagName); ok {
+		return prefix
+	}
+	if opts.autoPrefix && !sf.Anonymous {
+		return strings.ToLower(sf.Name) + "."
+	}
+	return ""
+}
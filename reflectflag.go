@@ -6,8 +6,12 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"os"
 	"reflect"
+	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 // Option configures how the structure is to be converted to and from flags.
@@ -18,7 +22,20 @@ type Option interface {
 }
 
 // TagName specifies which struct tag provides the flag name to use. If TagName
-// is not specified it defaults to "flag".
+// is not specified it defaults to "flag". The flag name may be followed by a
+// comma separated list of options:
+//
+//	required    the flag must be explicitly set; see CheckRequired.
+//	hidden      the flag is left out of PrintDefaults.
+//	short=x     the flag may also be set as -x.
+//	sep=;       slice and map values are separated by ';' rather than ','.
+//	deprecated=msg
+//	            setting the flag prints a warning including msg to the
+//	            FlagSet's output, and the flag is left out of PrintDefaults.
+//	env=NAME    the flag is read from the environment variable NAME, as
+//	            with the env tag.
+//
+// Option values may not contain commas.
 func TagName(tag string) Option {
 	return tagOpt(tag)
 }
@@ -40,17 +57,129 @@ func (o flagPrefixOpt) set(opts *options) {
 	opts.flagPrefix = string(o)
 }
 
+// PrefixTagName specifies which struct tag provides the prefix added to the
+// names of the flags within a nested struct field. If PrefixTagName is not
+// specified it defaults to "flagprefix". For example the flags of
+//
+//	DB struct {
+//		Host string `flag:"host"`
+//	} `flagprefix:"db."`
+//
+// are named "db.host". Prefixes of nested structs within nested structs are
+// concatenated, and are applied after the FlagPrefix. The tag may be used on
+// embedded structs too.
+func PrefixTagName(tag string) Option {
+	return prefixTagOpt(tag)
+}
+
+type prefixTagOpt string
+
+func (o prefixTagOpt) set(opts *options) {
+	opts.prefixTagName = string(o)
+}
+
+// AutoPrefix gives every nested struct field without a prefix tag the prefix
+// of its field name in lower case followed by a ".", so that the flags of a
+// field DB are named "db.<name>". Embedded structs are not given a prefix. An
+// empty prefix tag disables the prefix for a single field.
+func AutoPrefix() Option {
+	return autoPrefixOpt{}
+}
+
+type autoPrefixOpt struct{}
+
+func (autoPrefixOpt) set(opts *options) {
+	opts.autoPrefix = true
+}
+
+// HelpTagName specifies which struct tag provides the usage text of a flag. If
+// HelpTagName is not specified it defaults to "help". Fields without a help tag
+// are given a usage string generated from the field name and type.
+func HelpTagName(tag string) Option {
+	return helpTagOpt(tag)
+}
+
+type helpTagOpt string
+
+func (o helpTagOpt) set(opts *options) {
+	opts.helpTagName = string(o)
+}
+
+// DefaultTagName specifies which struct tag provides the default value of a
+// flag. If DefaultTagName is not specified it defaults to "default". The
+// default is parsed by the flag's Set method when the flag is registered and
+// takes precedence over the value of the field in the struct provided to
+// RegisterFlags. Slice defaults use the same comma separated form accepted on
+// the command line.
+func DefaultTagName(tag string) Option {
+	return defaultTagOpt(tag)
+}
+
+type defaultTagOpt string
+
+func (o defaultTagOpt) set(opts *options) {
+	opts.defaultTagName = string(o)
+}
+
+// EnvPrefix enables reading flag values from environment variables. A flag
+// that has no env tag is read from the variable named by prefix followed by the
+// flag name converted to upper case, with any character other than a letter or
+// digit replaced by an underscore. For example with EnvPrefix("MYAPP_") the
+// flag "db-url" is read from MYAPP_DB_URL.
+//
+// Environment values are applied with FlagSet.Set when the flag is registered.
+// They take precedence over default values, are overridden by values provided
+// on the command line, and count as explicitly set for CheckRequired.
+func EnvPrefix(prefix string) Option {
+	return envPrefixOpt(prefix)
+}
+
+type envPrefixOpt string
+
+func (o envPrefixOpt) set(opts *options) {
+	opts.envPrefix = string(o)
+	opts.envEnabled = true
+}
+
+// EnvTagName specifies which struct tag provides the name of the environment
+// variable to read a flag's value from. If EnvTagName is not specified it
+// defaults to "env". The variable named by the tag is used verbatim and is
+// consulted whether or not EnvPrefix is provided.
+func EnvTagName(tag string) Option {
+	return envTagOpt(tag)
+}
+
+type envTagOpt string
+
+func (o envTagOpt) set(opts *options) {
+	opts.envTagName = string(o)
+}
+
+// OnlySetFlags makes LoadFromFlags leave fields unchanged unless their flag was
+// explicitly set, as reported by FlagSet.Visit. This allows command line values
+// to be layered over a struct that has already been populated from elsewhere.
+func OnlySetFlags() Option {
+	return onlySetOpt{}
+}
+
+type onlySetOpt struct{}
+
+func (onlySetOpt) set(opts *options) {
+	opts.onlySet = true
+}
+
 // FlagGetterFactory accepts an interface type and returns a flag.Getter. When
 // a FlagGetterFactory is registered with a FlagType Option it will always be
 // invoked with an interface that matches the registered type.
 type FlagGetterFactory func(interface{}) flag.Getter
 
 // FlagType registers a new type. By default strings, boolean, integer,
-// floating point, and time.Duration values are understood. Registering a new
+// floating point, and time.Duration values are understood, as is any type
+// whose pointer implements flag.Value or encoding.TextUnmarshaler. Registering a new
 // type will allow the specified type (or any pointer indirection of the type),
-// to be used as struct fields or as elements within a slice. The flag.Getter
-// returned by the FlagGetterFactory should return a "deep copy" of the flag
-// value when Get() is invoked. This ensures that return values of
+// to be used as struct fields or as elements within a slice or map. The
+// flag.Getter returned by the FlagGetterFactory should return a "deep copy" of
+// the flag value when Get() is invoked. This ensures that return values of
 // LoadFromFlags will not share values between invocations unexpectedly.
 func FlagType(typ interface{}, factory FlagGetterFactory) Option {
 	return flagTypeOpt{
@@ -65,21 +194,50 @@ type flagTypeOpt struct {
 }
 
 func (o flagTypeOpt) set(opts *options) {
-	opts.ftypes[o.typ] = o.factory
+	for i := range opts.ftypes {
+		if opts.ftypes[i].typ == o.typ {
+			opts.ftypes[i].factory = o.factory
+			return
+		}
+	}
+	opts.ftypes = append(opts.ftypes, o)
 }
 
 type options struct {
-	tagName    string
-	flagPrefix string
-	ftypes     map[reflect.Type]FlagGetterFactory
+	tagName        string
+	helpTagName    string
+	defaultTagName string
+	envTagName     string
+	envPrefix      string
+	envEnabled     bool
+	flagPrefix     string
+	prefixTagName  string
+	autoPrefix     bool
+	bind           bool
+	skipEnv        bool
+	skipDefaults   bool
+	omitDefaults   bool
+	onlySet        bool
+	nilIfUnset     bool
+	duplicates     DuplicatePolicy
+	autoName       Namer
+	source         Source
+	flagFile       string
+	responseFiles  bool
+	deprecatedOut  io.Writer
+	ftypes         []flagTypeOpt // in registration order
 }
 
-func getOpts(opts ...Option) options {
-	o := options{
-		ftypes: map[reflect.Type]FlagGetterFactory{},
-	}
-	default_opts := []Option{
+// defaultOptions holds the result of applying the default Options. It is
+// computed once and copied by getOpts.
+var defaultOptions = func() options {
+	var o options
+	for _, x := range []Option{
 		TagName("flag"),
+		HelpTagName("help"),
+		DefaultTagName("default"),
+		EnvTagName("env"),
+		PrefixTagName("flagprefix"),
 		FlagType(true, newBoolValue),
 		FlagType(int(1), newIntValue),
 		FlagType(int32(1), newInt32Value),
@@ -91,123 +249,214 @@ func getOpts(opts ...Option) options {
 		FlagType(float64(1), newFloat64Value),
 		FlagType("string", newStringValue),
 		FlagType(time.Second, newDurationValue),
+	} {
+		x.set(&o)
 	}
-	for _, x := range append(default_opts, opts...) {
+	return o
+}()
+
+func getOpts(opts ...Option) options {
+	o := defaultOptions
+	o.ftypes = append(make([]flagTypeOpt, 0, len(o.ftypes)+len(opts)), o.ftypes...)
+	for _, x := range opts {
 		x.set(&o)
 	}
 	return o
 }
 
-// RegisterFlags adds the flags associated with a struct to the Flagset.
+// RegisterFlags adds the flags associated with a struct to the Flagset. A flag
+// name that is already in use is handled as set by OnDuplicate.
 func RegisterFlags(flags *flag.FlagSet, s interface{}, opts ...Option) error {
 	v := reflect.ValueOf(s)
 	if v.Kind() != reflect.Struct {
-		return fmt.Errorf("unable to register flags for %q: not a struct type", v.Type())
+		return fmt.Errorf("unable to register flags for %q: %w", typeString(s), ErrNotStruct)
 	}
 	o := getOpts(opts...)
-	return registerStructFields(flags, v, o)
+	p, err := getPlan(v.Type(), o, "register")
+	if err != nil {
+		return err
+	}
+	registerFlagFile(flags, o)
+	return registerPlan(flags, v, p, o)
 }
 
-func registerStructFields(flags *flag.FlagSet, v reflect.Value, opts options) error {
-	typ := v.Type()
-	for i := 0; i < typ.NumField(); i++ {
-		sf := typ.Field(i)
-		if sf.PkgPath != "" {
-			// skip non-exported fields
-			continue
+func registerPlan(flags *flag.FlagSet, v reflect.Value, p *structPlan, opts options) error {
+	if opts.duplicates == DuplicateError {
+		if err := checkDuplicates(flags, p); err != nil {
+			return err
+		}
+	}
+	for i := range p.fields {
+		fp := &p.fields[i]
+		if err := registerField(flags, v.FieldByIndex(fp.index), fp, opts); err != nil {
+			return &FieldError{Op: "register", Type: p.typ, Field: fp.path, Flag: fp.name, Err: err}
 		}
-		if err := registerStructField(flags, sf, v.Field(i), opts); err != nil {
-			return fmt.Errorf("unable to register flag for field %s.%s: %v", typ, sf.Name, err)
+		if (fp.spec.hidden || fp.spec.deprecated != "") && hasDefaultUsage(flags) {
+			flags.Usage = func() { printUsage(flags) }
 		}
 	}
 	return nil
 }
 
-func registerStructField(flags *flag.FlagSet, sf reflect.StructField, v reflect.Value, opts options) error {
-	tag := sf.Tag.Get(opts.tagName)
-	if tag == "" {
-		derefV := derefFully(v)
-		if v.Kind() == reflect.Struct {
-			return registerStructFields(flags, derefV, opts)
+func registerField(flags *flag.FlagSet, v reflect.Value, fp *fieldPlan, opts options) error {
+	fg := fp.newGetter(v, opts)
+	if fp.hasDef && !opts.skipDefaults {
+		if err := fg.Set(fp.def); err != nil {
+			return fmt.Errorf("invalid default value %q: %w", fp.def, err)
 		}
-		return nil
+		// The default should be replaced, not extended, by the first
+		// value provided for the flag.
+		resetMerge(fg)
 	}
-	if fg := flagGetterForValue(v, opts); fg != nil {
-		flags.Var(fg, opts.flagPrefix+tag, fmt.Sprintf("Set %s.%s", v.Type(), sf.Name))
-		return nil
+	if opts.bind {
+		if fp.hasDef && !opts.skipDefaults {
+			if err := setFieldFromFlag(v, fp.name, fg); err != nil {
+				return err
+			}
+		}
+		fg = &boundValue{wrappedValue: wrappedValue{fg}, dst: v, name: fp.name}
 	}
-	derefV := derefFully(v)
-	if derefV.Kind() != reflect.Slice {
-		return fmt.Errorf("no flag factory registered for %v", v.Type())
+	var fv flag.Value = fg
+	if fp.spec.hidden || fp.spec.deprecated != "" {
+		fv = &specValue{wrappedValue: wrappedValue{fg}, flags: flags, out: opts.deprecatedOut, name: fp.name, hidden: fp.spec.hidden, deprecated: fp.spec.deprecated}
 	}
-	sv := &sliceValue{}
-	for i := 0; i < derefV.Len(); i++ {
-		e := derefV.Index(i)
-		sv.f = flagGetterForValue(e, opts)
-		if sv.f == nil {
-			return fmt.Errorf("no flag factory registered for %v", e.Type())
+	if f := flags.Lookup(fp.name); f != nil {
+		if opts.duplicates == DuplicateSkip {
+			return nil
 		}
-		sv.values = append(sv.values, sv.f.String())
+		f.Value = fv
+		f.Usage = fp.usage
+		f.DefValue = fv.String()
+	} else {
+		flags.Var(fv, fp.name, fp.usage)
+	}
+	if fp.spec.short != "" {
+		registerAlias(flags, fp, opts)
 	}
-	if sv.f == nil {
-		sv.f = flagGetterForValue(reflect.Zero(derefV.Type().Elem()), opts)
-		if sv.f == nil {
-			return fmt.Errorf("no flag factory registered for %v", derefV.Type().Elem())
+	if fp.env != "" && !opts.skipEnv {
+		if val, ok := os.LookupEnv(fp.env); ok {
+			if err := flags.Set(fp.name, val); err != nil {
+				return fmt.Errorf("invalid value %q for environment variable %s: %w", val, fp.env, err)
+			}
+			// Likewise the environment value is replaced by the command line.
+			resetMerge(fv)
 		}
 	}
-	flags.Var(sv, opts.flagPrefix+tag, fmt.Sprintf("Set %s.%s", v.Type, sf.Name))
 	return nil
 }
 
+// envNameForField returns the name of the environment variable named by the
+// tag of sf, or "" if there is none.
+func envNameForField(sf reflect.StructField, spec fieldSpec, opts options) string {
+	if spec.env != "" {
+		return spec.env
+	}
+	return sf.Tag.Get(opts.envTagName)
+}
+
+// envNameForFlag returns the name of the environment variable derived from a
+// flag name with EnvPrefix.
+func envNameForFlag(flagName string, opts options) string {
+	return opts.envPrefix + strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, flagName)
+}
+
+// usageForField returns the usage string for the flag associated with sf. The
+// help tag is used when present, otherwise a description is generated from the
+// field name and type.
+func usageForField(sf reflect.StructField, opts options) string {
+	if help := sf.Tag.Get(opts.helpTagName); help != "" {
+		return help
+	}
+	return fmt.Sprintf("Set %s (%v)", sf.Name, sf.Type)
+}
+
+// Bind adds the flags associated with the struct pointed to by s to the
+// FlagSet, like RegisterFlags, but the registered flags write their values
+// directly into the fields of s whenever they are set. After FlagSet.Parse
+// returns, s holds the parsed configuration and there is no need to call
+// LoadFromFlags. Nil pointer fields are only allocated when their flag is set.
+// Use CheckRequired after parsing to validate required flags.
+func Bind(flags *flag.FlagSet, s interface{}, opts ...Option) error {
+	v := reflect.ValueOf(s)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("unable to bind flags for %q: %w", typeString(s), ErrNotStructPointer)
+	}
+	o := getOpts(opts...)
+	o.bind = true
+	p, err := getPlan(v.Elem().Type(), o, "register")
+	if err != nil {
+		return err
+	}
+	registerFlagFile(flags, o)
+	return registerPlan(flags, v.Elem(), p, o)
+}
+
 // LoadFromFlags populates s with the current values of the flags in the FlagSet.
+// With the OnlySetFlags Option only the fields whose flags were explicitly set
+// are changed.
 func LoadFromFlags(flags *flag.FlagSet, s interface{}, opts ...Option) error {
 	v := reflect.ValueOf(s)
 	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
-		return fmt.Errorf("unable to load from flags for %q: not a pointer to a struct", v.Type())
+		return fmt.Errorf("unable to load from flags for %q: %w", typeString(s), ErrNotStructPointer)
 	}
 	if !v.Elem().CanSet() {
 		return fmt.Errorf("unable to load from flags for %q: struct is not settable", v.Type())
 	}
 	o := getOpts(opts...)
-	return loadFromStructFields(flags, v.Elem(), o)
-}
-
-func loadFromStructFields(flags *flag.FlagSet, v reflect.Value, opts options) error {
-	typ := v.Type()
-	for i := 0; i < typ.NumField(); i++ {
-		sf := typ.Field(i)
-		if sf.PkgPath != "" {
-			// skip non-exported fields
+	p, err := getPlan(v.Elem().Type(), o, "load")
+	if err != nil {
+		return err
+	}
+	var set map[string]bool
+	if o.onlySet {
+		set = map[string]bool{}
+		flags.Visit(func(f *flag.Flag) {
+			set[f.Name] = true
+		})
+	}
+	for i := range p.fields {
+		fp := &p.fields[i]
+		if set != nil && !set[fp.name] {
 			continue
 		}
-		if err := loadFromStructField(flags, sf, v.Field(i), opts); err != nil {
-			return fmt.Errorf("unable to load flag for field %s.%s: %v", typ, sf.Name, err)
+		if err := loadField(flags, v.Elem().FieldByIndex(fp.index), fp); err != nil {
+			return &FieldError{Op: "load", Type: p.typ, Field: fp.path, Flag: fp.name, Err: err}
 		}
 	}
-	return nil
+	return checkRequired(flags, p)
 }
 
-func loadFromStructField(flags *flag.FlagSet, sf reflect.StructField, v reflect.Value, opts options) error {
-	tag := sf.Tag.Get(opts.tagName)
-	if tag == "" {
-		derefV := derefFully(v)
-		if derefV.Kind() == reflect.Struct {
-			return loadFromStructFields(flags, derefV, opts)
-		}
-		return nil
-	}
-	flagName := opts.flagPrefix + tag
-	flg := flags.Lookup(flagName)
+func loadField(flags *flag.FlagSet, v reflect.Value, fp *fieldPlan) error {
+	flg := flags.Lookup(fp.name)
 	if flg == nil {
-		return fmt.Errorf("unable to lookup flag %q. Was RegisterFlags called?", flagName)
+		return fmt.Errorf("%w: %q (was RegisterFlags called?)", ErrFlagNotRegistered, fp.name)
+	}
+	fg, ok := unwrapValue(flg.Value).(flag.Getter)
+	if !ok {
+		return fmt.Errorf("flag %q doesn't implement the flag.Getter interface", fp.name)
 	}
-	flgVal := flg.Value
-	switch flagGetter := flgVal.(type) {
-	case *sliceValue:
+	return setFieldFromFlag(v, fp.name, fg)
+}
+
+// setFieldFromFlag sets the field v to the current value of the flag.Getter fg
+// associated with the flag named flagName.
+func setFieldFromFlag(v reflect.Value, flagName string, fg flag.Getter) error {
+	if ov, ok := fg.(*optionalValue); ok {
+		return setFieldFromOptional(v, flagName, ov)
+	}
+	if sv, ok := fg.(*sliceValue); ok {
 		if v.Type().Kind() != reflect.Slice {
 			return fmt.Errorf("mismatched flag and field type. Flag %q is a slice, field is %v", flagName, v.Type())
 		}
-		flgValues := flagGetter.Get().([]interface{})
+		flgValues := sv.Get().([]interface{})
 		newValues := reflect.MakeSlice(reflect.SliceOf(v.Type().Elem()), 0, len(flgValues))
 		for _, fv := range flgValues {
 			newV, err := convertValueTo(reflect.ValueOf(fv), v.Type().Elem())
@@ -217,17 +466,127 @@ func loadFromStructField(flags *flag.FlagSet, sf reflect.StructField, v reflect.
 			newValues = reflect.Append(newValues, newV)
 		}
 		v.Set(newValues)
-	case flag.Getter:
-		newV, err := convertValueTo(reflect.ValueOf(flagGetter.Get()), v.Type())
-		if err != nil {
-			return err
+		return nil
+	}
+	if mv, ok := fg.(*mapValue); ok {
+		if v.Type().Kind() != reflect.Map {
+			return fmt.Errorf("mismatched flag and field type. Flag %q is a map, field is %v", flagName, v.Type())
+		}
+		flgValues := mv.Get().(map[string]interface{})
+		newValues := reflect.MakeMapWithSize(v.Type(), len(flgValues))
+		for k, fv := range flgValues {
+			newV, err := convertValueTo(reflect.ValueOf(fv), v.Type().Elem())
+			if err != nil {
+				return err
+			}
+			newValues.SetMapIndex(reflect.ValueOf(k).Convert(v.Type().Key()), newV)
 		}
-		v.Set(newV)
+		v.Set(newValues)
+		return nil
+	}
+	newV, err := convertValueTo(reflect.ValueOf(fg.Get()), v.Type())
+	if err != nil {
+		return err
+	}
+	v.Set(newV)
+	return nil
+}
+
+// CheckRequired returns an error listing every flag of s marked as required
+// that was not explicitly set in the FlagSet. Only flags set on the command line
+// (or with FlagSet.Set) are considered set; default values are not. s may be a
+// struct or a pointer to a struct. LoadFromFlags performs the same check after
+// populating its struct.
+func CheckRequired(flags *flag.FlagSet, s interface{}, opts ...Option) error {
+	typ := reflect.TypeOf(s)
+	if typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return fmt.Errorf("unable to check required flags for %q: %w", reflect.TypeOf(s), ErrNotStruct)
+	}
+	p, err := getPlan(typ, getOpts(opts...), "check")
+	if err != nil {
+		return err
+	}
+	return checkRequired(flags, p)
+}
+
+func checkRequired(flags *flag.FlagSet, p *structPlan) error {
+	if len(p.required) == 0 {
 		return nil
-	default:
-		return fmt.Errorf("flag %q doesn't implement the flag.Getter interface", flagName)
 	}
-	return nil // unreachable
+	set := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	var missing []string
+	for _, name := range p.required {
+		if !set[name] {
+			missing = append(missing, "-"+name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing required flags: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// fieldSpec is the parsed form of a flag struct tag.
+type fieldSpec struct {
+	name       string
+	required   bool
+	hidden     bool
+	short      string
+	sep        rune // separator of slice and map values, or 0 for ','
+	deprecated string
+	env        string
+}
+
+// parseFlagTag parses a flag struct tag of the form "name[,option...]", where
+// each option is either a bare word or of the form key=value. The name may be
+// empty if it is generated by AutoName.
+func parseFlagTag(tag string) (fieldSpec, error) {
+	parts := strings.Split(tag, ",")
+	spec := fieldSpec{name: parts[0]}
+	for _, opt := range parts[1:] {
+		key, val, hasVal := strings.Cut(opt, "=")
+		switch key {
+		case "required", "hidden":
+			if hasVal {
+				return spec, fmt.Errorf("option %q in tag %q does not take a value", key, tag)
+			}
+		case "short", "sep", "deprecated", "env":
+			if val == "" {
+				return spec, fmt.Errorf("option %q in tag %q requires a value", key, tag)
+			}
+		default:
+			return spec, fmt.Errorf("unknown option %q in tag %q", opt, tag)
+		}
+		switch key {
+		case "required":
+			spec.required = true
+		case "hidden":
+			spec.hidden = true
+		case "short":
+			r := []rune(val)
+			if len(r) != 1 || r[0] == '-' || r[0] == '=' || r[0] == utf8.RuneError {
+				return spec, fmt.Errorf("invalid short name %q in tag %q", val, tag)
+			}
+			spec.short = val
+		case "sep":
+			r := []rune(val)
+			if len(r) != 1 || r[0] == '"' || r[0] == '\r' || r[0] == '\n' || r[0] == utf8.RuneError {
+				return spec, fmt.Errorf("invalid separator %q in tag %q", val, tag)
+			}
+			spec.sep = r[0]
+		case "deprecated":
+			spec.deprecated = val
+		case "env":
+			spec.env = val
+		}
+	}
+	return spec, nil
 }
 
 // derefFully dereferences pointer values until it reaches a non-pointer value.
@@ -290,15 +649,30 @@ func convertValueTo(v reflect.Value, typ reflect.Type) (reflect.Value, error) {
 	return reflect.Zero(typ), errors.New("unreachable")
 }
 
-// flagGetterForValue returns the flag.Getter for the specified value. If no
-// flag factory is registered for the value nil will be returned.
+// flagGetterForValue returns the flag.Getter for the specified value, chosen as
+// described by resolveType. If no flag.Getter can be created nil will be
+// returned.
 func flagGetterForValue(v reflect.Value, opts options) flag.Getter {
-	for typ, factory := range opts.ftypes {
-		cv, err := convertValueTo(v, typ)
-		if err != nil {
-			continue
-		}
-		return factory(cv.Interface())
+	res, ok := resolveType(v.Type(), opts)
+	if !ok {
+		return nil
 	}
-	return nil
+	return res.newGetter(v, opts)
+}
+
+// typeString returns the name of the type of s for use in error messages.
+func typeString(s interface{}) string {
+	if s == nil {
+		return "nil"
+	}
+	return reflect.TypeOf(s).String()
+}
+
+// baseType returns the type reached by removing all pointer indirection from
+// typ.
+func baseType(typ reflect.Type) reflect.Type {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return typ
 }
@@ -5,7 +5,13 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/netip"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -35,17 +41,38 @@ func deepEqual(a, b interface{}) bool {
 	}
 	switch va.Kind() {
 	case reflect.Ptr:
+		if va.IsNil() || vb.IsNil() {
+			return va.IsNil() == vb.IsNil()
+		}
 		return deepEqual(va.Elem().Interface(), vb.Elem().Interface())
 	case reflect.Struct:
 		typ := va.Type()
+		exported := false
 		for i := 0; i < typ.NumField(); i++ {
 			if typ.Field(i).PkgPath != "" {
 				continue // skip unexported fields
 			}
+			exported = true
 			if !deepEqual(va.Field(i).Interface(), vb.Field(i).Interface()) {
 				return false
 			}
 		}
+		if !exported {
+			// opaque types such as big.Int
+			return reflect.DeepEqual(a, b)
+		}
+		return true
+	case reflect.Map:
+		if va.Len() != vb.Len() {
+			return false
+		}
+		iter := va.MapRange()
+		for iter.Next() {
+			bv := vb.MapIndex(iter.Key())
+			if !bv.IsValid() || !deepEqual(iter.Value().Interface(), bv.Interface()) {
+				return false
+			}
+		}
 		return true
 	case reflect.Slice:
 		if va.Len() != vb.Len() {
@@ -123,7 +150,7 @@ func (c *customFlag) Set(s string) error {
 		*c.t = customType(3)
 		return nil
 	}
-	fmt.Println("Set custom type to: %v", *c.t)
+	fmt.Printf("Set custom type to: %v\n", *c.t)
 	return errors.New("unable to set customType")
 }
 
@@ -132,6 +159,59 @@ func (c *customFlag) Get() interface{} {
 	return &x
 }
 
+// upperValue implements flag.Value but not flag.Getter.
+type upperValue string
+
+func (u *upperValue) Set(s string) error {
+	*u = upperValue(strings.ToUpper(s))
+	return nil
+}
+
+func (u *upperValue) String() string { return string(*u) }
+
+// onOffValue implements a boolean flag.Value.
+type onOffValue bool
+
+func (o *onOffValue) Set(s string) error {
+	*o = s == "true" || s == "on"
+	return nil
+}
+
+func (o *onOffValue) String() string {
+	if *o {
+		return "on"
+	}
+	return "off"
+}
+
+func (o *onOffValue) IsBoolFlag() bool { return true }
+
+// listValue implements flag.Getter and accumulates repeated values.
+type listValue []string
+
+func (l *listValue) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+func (l *listValue) String() string { return strings.Join(*l, ";") }
+
+func (l *listValue) Get() interface{} { return append(listValue(nil), *l...) }
+
+// levelValue implements flag.Getter with Get returning an int, like the
+// flag.Getters of the standard library.
+type levelValue int
+
+func (l *levelValue) Set(s string) error {
+	n, err := strconv.Atoi(s)
+	*l = levelValue(n)
+	return err
+}
+
+func (l *levelValue) String() string { return strconv.Itoa(int(*l)) }
+
+func (l *levelValue) Get() interface{} { return int(*l) }
+
 type testCase struct {
 	desc            string
 	testStruct      interface{}
@@ -618,6 +698,243 @@ var tests []testCase = []testCase{
 			A: []string{`with,comma`, `with"quote`, `with space`},
 		},
 	},
+	{
+		desc: "default tags",
+		testStruct: struct {
+			S   string         `flag:"s" default:"from_tag"`
+			P   *int           `flag:"p" default:"42"`
+			Sl  []string       `flag:"sl" default:"a,\"b,c\""`
+			D   time.Duration  `flag:"d" default:"1m"`
+			Ovr int            `flag:"ovr" default:"7"`
+			E   []int          `flag:"e" default:""`
+			Nd  *time.Duration `flag:"nd"`
+		}{
+			S:   "from_struct",
+			E:   []int{1, 2},
+			Ovr: 3,
+		},
+		wantPreParse: map[string]interface{}{
+			"s":   "from_tag",
+			"p":   int(42),
+			"sl":  []interface{}{"a", "b,c"},
+			"d":   time.Minute,
+			"ovr": int(7),
+			"e":   []interface{}(nil),
+			"nd":  time.Duration(0),
+		},
+		args: []string{
+			"--ovr=8",
+		},
+		wantStruct: struct {
+			S   string         `flag:"s" default:"from_tag"`
+			P   *int           `flag:"p" default:"42"`
+			Sl  []string       `flag:"sl" default:"a,\"b,c\""`
+			D   time.Duration  `flag:"d" default:"1m"`
+			Ovr int            `flag:"ovr" default:"7"`
+			E   []int          `flag:"e" default:""`
+			Nd  *time.Duration `flag:"nd"`
+		}{
+			S:   "from_tag",
+			P:   ptrTo(int(42)).(*int),
+			Sl:  []string{"a", "b,c"},
+			D:   time.Minute,
+			Ovr: 8,
+			E:   []int{},
+			Nd:  new(time.Duration),
+		},
+	},
+	{
+		desc: "invalid default tag",
+		testStruct: struct {
+			I int `flag:"i" default:"ten"`
+		}{},
+		wantRegisterErr: errors.New(`unable to register flag for field struct { I int "flag:\"i\" default:\"ten\"" }.I: invalid default value "ten": strconv.ParseInt: parsing "ten": invalid syntax`),
+	},
+	{
+		desc: "required flags set",
+		testStruct: struct {
+			A string `flag:"a,required"`
+			B int    `flag:"b,required" default:"3"`
+		}{},
+		args: []string{
+			"--a=foo",
+			"--b=3",
+		},
+		wantStruct: struct {
+			A string `flag:"a,required"`
+			B int    `flag:"b,required" default:"3"`
+		}{
+			A: "foo",
+			B: 3,
+		},
+	},
+	{
+		desc: "required flags missing",
+		testStruct: struct {
+			A string `flag:"a,required"`
+			B int    `flag:"b,required" default:"3"`
+			C bool   `flag:"c"`
+		}{},
+		args: []string{
+			"--c",
+		},
+		wantLoadErr: errors.New(`missing required flags: -a, -b`),
+	},
+	{
+		desc: "unknown tag option",
+		testStruct: struct {
+			A string `flag:"a,requried"`
+		}{},
+		wantRegisterErr: errors.New(`unable to register flag for field struct { A string "flag:\"a,requried\"" }.A: unknown option "requried" in tag "a,requried"`),
+	},
+	{
+		desc: "map types",
+		testStruct: struct {
+			Labels   map[string]string        `flag:"labels"`
+			Limits   map[string]*int          `flag:"limits" default:"cpu=2"`
+			Timeouts map[string]time.Duration `flag:"timeouts"`
+			Empty    map[string]bool          `flag:"empty"`
+		}{
+			Labels:   map[string]string{"env": "dev", "team": "web"},
+			Timeouts: map[string]time.Duration{"read": time.Second},
+		},
+		wantPreParse: map[string]interface{}{
+			"labels":   map[string]interface{}{"env": "dev", "team": "web"},
+			"limits":   map[string]interface{}{"cpu": int(2)},
+			"timeouts": map[string]interface{}{"read": time.Second},
+			"empty":    map[string]interface{}{},
+		},
+		args: []string{
+			"--labels=env=prod,team=infra",
+			"--labels", "owner=ops",
+			"--limits=mem=4",
+			"--timeouts=write=1m",
+			"--timeouts", `"csv,x=1h"`,
+		},
+		wantStruct: struct {
+			Labels   map[string]string        `flag:"labels"`
+			Limits   map[string]*int          `flag:"limits" default:"cpu=2"`
+			Timeouts map[string]time.Duration `flag:"timeouts"`
+			Empty    map[string]bool          `flag:"empty"`
+		}{
+			Labels:   map[string]string{"env": "prod", "team": "infra", "owner": "ops"},
+			Limits:   map[string]*int{"mem": ptrTo(int(4)).(*int)},
+			Timeouts: map[string]time.Duration{"write": time.Minute, "csv,x": time.Hour},
+			Empty:    map[string]bool{},
+		},
+	},
+	{
+		desc: "invalid map entry",
+		testStruct: struct {
+			Labels map[string]string `flag:"labels"`
+		}{},
+		args: []string{
+			"--labels=env",
+		},
+		wantParseErr: errors.New(`invalid value "env" for flag -labels: invalid map entry "env": expected key=value`),
+	},
+	{
+		desc: "unsupported map key",
+		testStruct: struct {
+			M map[int]string `flag:"m"`
+		}{},
+		wantRegisterErr: errors.New(`unable to register flag for field struct { M map[int]string "flag:\"m\"" }.M: unsupported map key type int: only string keys are supported`),
+	},
+	{
+		desc: "text unmarshaler types",
+		testStruct: struct {
+			IP    net.IP                `flag:"ip"`
+			Addr  *netip.Addr           `flag:"addr" default:"10.0.0.1"`
+			Big   *big.Int              `flag:"big"`
+			Level slog.Level            `flag:"level"`
+			IPs   []net.IP              `flag:"ips"`
+			Peers map[string]netip.Addr `flag:"peers"`
+		}{
+			IP:    net.ParseIP("127.0.0.1"),
+			Level: slog.LevelWarn,
+		},
+		wantPreParse: map[string]interface{}{
+			"ip":    net.ParseIP("127.0.0.1"),
+			"addr":  netip.MustParseAddr("10.0.0.1"),
+			"big":   *big.NewInt(0),
+			"level": slog.LevelWarn,
+			"ips":   []interface{}(nil),
+			"peers": map[string]interface{}{},
+		},
+		args: []string{
+			"--ip=::1",
+			"--big=123456789012345678901234567890",
+			"--level=debug",
+			"--ips=10.1.1.1,10.1.1.2",
+			"--peers=a=192.168.0.1",
+		},
+		wantStruct: struct {
+			IP    net.IP                `flag:"ip"`
+			Addr  *netip.Addr           `flag:"addr" default:"10.0.0.1"`
+			Big   *big.Int              `flag:"big"`
+			Level slog.Level            `flag:"level"`
+			IPs   []net.IP              `flag:"ips"`
+			Peers map[string]netip.Addr `flag:"peers"`
+		}{
+			IP:    net.ParseIP("::1"),
+			Addr:  ptrTo(netip.MustParseAddr("10.0.0.1")).(*netip.Addr),
+			Big:   func() *big.Int { b, _ := new(big.Int).SetString("123456789012345678901234567890", 10); return b }(),
+			Level: slog.LevelDebug,
+			IPs:   []net.IP{net.ParseIP("10.1.1.1"), net.ParseIP("10.1.1.2")},
+			Peers: map[string]netip.Addr{"a": netip.MustParseAddr("192.168.0.1")},
+		},
+	},
+	{
+		desc: "invalid text value",
+		testStruct: struct {
+			Addr netip.Addr `flag:"addr"`
+		}{},
+		args: []string{
+			"--addr=nope",
+		},
+		wantParseErr: errors.New(`invalid value "nope" for flag -addr: ParseAddr("nope"): unable to parse IP`),
+	},
+	{
+		desc: "flag.Value types",
+		testStruct: struct {
+			Upper    upperValue  `flag:"upper"`
+			UpperPtr *upperValue `flag:"upper_ptr"`
+			OnOff    onOffValue  `flag:"onoff"`
+			List     listValue   `flag:"list"`
+			Level    levelValue  `flag:"level"`
+		}{
+			List:  listValue{"initial"},
+			Level: 1,
+		},
+		wantPreParse: map[string]interface{}{
+			"upper":     upperValue(""),
+			"upper_ptr": upperValue(""),
+			"onoff":     onOffValue(false),
+			"list":      listValue{"initial"},
+			"level":     levelValue(1),
+		},
+		args: []string{
+			"--upper=shout",
+			"--upper_ptr=also",
+			"--onoff",
+			"--list=a",
+			"--list=b",
+			"--level=3",
+		},
+		wantStruct: struct {
+			Upper    upperValue  `flag:"upper"`
+			UpperPtr *upperValue `flag:"upper_ptr"`
+			OnOff    onOffValue  `flag:"onoff"`
+			List     listValue   `flag:"list"`
+			Level    levelValue  `flag:"level"`
+		}{
+			Upper:    "SHOUT",
+			UpperPtr: ptrTo(upperValue("ALSO")).(*upperValue),
+			OnOff:    true,
+			List:     listValue{"initial", "a", "b"},
+			Level:    3,
+		},
+	},
 }
 
 func TestRegisterAndLoadFlags(t *testing.T) {
@@ -702,3 +1019,444 @@ func TestConvertValueTo(t *testing.T) {
 		}
 	}
 }
+
+func TestUsage(t *testing.T) {
+	for _, tc := range []struct {
+		desc       string
+		testStruct interface{}
+		opts       []Option
+		want       map[string]string
+	}{
+		{
+			desc: "help tag",
+			testStruct: struct {
+				Addr  string   `flag:"addr" help:"Address to listen on"`
+				Hosts []string `flag:"hosts" help:"Backend hosts"`
+			}{},
+			want: map[string]string{
+				"addr":  "Address to listen on",
+				"hosts": "Backend hosts",
+			},
+		},
+		{
+			desc: "generated usage",
+			testStruct: struct {
+				Timeout *time.Duration `flag:"timeout"`
+			}{},
+			want: map[string]string{
+				"timeout": "Set Timeout (*time.Duration)",
+			},
+		},
+		{
+			desc: "custom help tag",
+			testStruct: struct {
+				Addr string `flag:"addr" help:"ignored" usage:"Address to listen on"`
+			}{},
+			opts: []Option{HelpTagName("usage")},
+			want: map[string]string{
+				"addr": "Address to listen on",
+			},
+		},
+	} {
+		flags := flag.NewFlagSet("testflags", flag.ContinueOnError)
+		if err := RegisterFlags(flags, tc.testStruct, tc.opts...); err != nil {
+			t.Errorf("TestUsage %q: unexpected error from RegisterFlags: %v", tc.desc, err)
+			continue
+		}
+		for name, want := range tc.want {
+			f := flags.Lookup(name)
+			if f == nil {
+				t.Errorf("TestUsage %q: missing flag %s", tc.desc, name)
+				continue
+			}
+			if f.Usage != want {
+				t.Errorf("TestUsage %q: unexpected usage for flag %s; got %q want %q", tc.desc, name, f.Usage, want)
+			}
+		}
+	}
+}
+
+func TestCheckRequired(t *testing.T) {
+	type inner struct {
+		Host string `flag:"host,required"`
+	}
+	type config struct {
+		Name  string `flag:"name,required"`
+		Inner inner
+	}
+	flags := flag.NewFlagSet("testflags", flag.ContinueOnError)
+	opts := []Option{FlagPrefix("x_")}
+	if err := RegisterFlags(flags, config{}, opts...); err != nil {
+		t.Fatalf("unexpected error from RegisterFlags: %v", err)
+	}
+	want := "missing required flags: -x_name, -x_host"
+	if err := CheckRequired(flags, config{}, opts...); fmt.Sprintf("%v", err) != want {
+		t.Errorf("unexpected error from CheckRequired; got %v want %v", err, want)
+	}
+	if err := flags.Parse([]string{"--x_name=foo", "--x_host=bar"}); err != nil {
+		t.Fatalf("unexpected error from Parse: %v", err)
+	}
+	if err := CheckRequired(flags, &config{}, opts...); err != nil {
+		t.Errorf("unexpected error from CheckRequired: %v", err)
+	}
+}
+
+func TestEnv(t *testing.T) {
+	type config struct {
+		URL     string            `flag:"db-url,required"`
+		Port    int               `flag:"port" default:"80"`
+		Hosts   []string          `flag:"hosts"`
+		Level   *int              `flag:"level" env:"LOG_LEVEL"`
+		Verbose bool              `flag:"verbose"`
+		Labels  map[string]string `flag:"labels"`
+		Tags    map[string]string `flag:"tags"`
+	}
+	t.Setenv("MYAPP_DB_URL", "postgres://env")
+	t.Setenv("MYAPP_LABELS", "a=1")
+	t.Setenv("MYAPP_TAGS", "x=1")
+	t.Setenv("MYAPP_PORT", "8080")
+	t.Setenv("MYAPP_HOSTS", "a,b")
+	t.Setenv("LOG_LEVEL", "3")
+	opts := []Option{EnvPrefix("MYAPP_")}
+
+	flags := flag.NewFlagSet("testflags", flag.ContinueOnError)
+	if err := RegisterFlags(flags, config{}, opts...); err != nil {
+		t.Fatalf("unexpected error from RegisterFlags: %v", err)
+	}
+	if err := flags.Parse([]string{"--port=9090", "--labels=b=2", "--labels=c=3"}); err != nil {
+		t.Fatalf("unexpected error from Parse: %v", err)
+	}
+	var got config
+	if err := LoadFromFlags(flags, &got, opts...); err != nil {
+		t.Fatalf("unexpected error from LoadFromFlags: %v", err)
+	}
+	want := config{
+		URL:   "postgres://env",
+		Port:  9090,
+		Hosts: []string{"a", "b"},
+		Level: ptrTo(int(3)).(*int),
+		// The command line replaces the environment value of a map.
+		Labels: map[string]string{"b": "2", "c": "3"},
+		Tags:   map[string]string{"x": "1"},
+	}
+	if !deepEqual(got, want) {
+		t.Errorf("unexpected output from LoadFromFlags; got %#v want %#v", got, want)
+	}
+
+	t.Setenv("MYAPP_VERBOSE", "sometimes")
+	flags = flag.NewFlagSet("testflags", flag.ContinueOnError)
+	wantErr := `unable to register flag for field reflectflag.config.Verbose: invalid value "sometimes" for environment variable MYAPP_VERBOSE: strconv.ParseBool: parsing "sometimes": invalid syntax`
+	if err := RegisterFlags(flags, config{}, opts...); fmt.Sprintf("%v", err) != wantErr {
+		t.Errorf("unexpected error from RegisterFlags; got %v want %v", err, wantErr)
+	}
+}
+
+func TestBind(t *testing.T) {
+	type inner struct {
+		Host string `flag:"host" default:"localhost"`
+	}
+	type config struct {
+		Name    string         `flag:"name"`
+		Verbose bool           `flag:"verbose"`
+		Port    *int           `flag:"port"`
+		Timeout *time.Duration `flag:"timeout"`
+		Tags    []string       `flag:"tags"`
+		Ports   []int          `flag:"ports" default:"80,443"`
+		Retries int            `flag:"retries,required" env:"TEST_BIND_RETRIES"`
+		Inner   inner
+	}
+	t.Setenv("TEST_BIND_RETRIES", "5")
+	cfg := config{Name: "initial"}
+	var b bytes.Buffer
+	flags := flag.NewFlagSet("testflags", flag.ContinueOnError)
+	flags.SetOutput(&b)
+	if err := Bind(flags, &cfg); err != nil {
+		t.Fatalf("unexpected error from Bind: %v", err)
+	}
+	if cfg.Inner.Host != "localhost" || cfg.Retries != 5 {
+		t.Errorf("default and env values not bound before Parse: %#v", cfg)
+	}
+	if err := flags.Parse([]string{"--verbose", "--port=8080", "--tags=a,b"}); err != nil {
+		t.Fatalf("unexpected error from Parse: %v", err)
+	}
+	if err := CheckRequired(flags, &cfg); err != nil {
+		t.Errorf("unexpected error from CheckRequired: %v", err)
+	}
+	want := config{
+		Name:    "initial",
+		Verbose: true,
+		Port:    ptrTo(int(8080)).(*int),
+		Tags:    []string{"a", "b"},
+		Ports:   []int{80, 443},
+		Retries: 5,
+		Inner:   inner{Host: "localhost"},
+	}
+	if !deepEqual(cfg, want) {
+		t.Errorf("unexpected struct after Parse; got %#v want %#v", cfg, want)
+	}
+	if cfg.Timeout != nil {
+		t.Errorf("unset pointer field was allocated: %v", *cfg.Timeout)
+	}
+	var loaded config
+	if err := LoadFromFlags(flags, &loaded); err != nil {
+		t.Errorf("unexpected error from LoadFromFlags: %v", err)
+	}
+	flags.PrintDefaults()
+	if strings.Contains(b.String(), "panic") {
+		t.Errorf("PrintDefaults failed: %s", b.String())
+	}
+	if err := Bind(flag.NewFlagSet("testflags", flag.ContinueOnError), cfg); err == nil {
+		t.Errorf("expected Bind to reject a non-pointer")
+	}
+	for _, s := range []interface{}{nil, (*config)(nil)} {
+		fs := flag.NewFlagSet("testflags", flag.ContinueOnError)
+		if err := Bind(fs, s); !errors.Is(err, ErrNotStructPointer) {
+			t.Errorf("Bind(%#v) = %v, want ErrNotStructPointer", s, err)
+		}
+		if err := LoadFromFlags(fs, s); !errors.Is(err, ErrNotStructPointer) {
+			t.Errorf("LoadFromFlags(%#v) = %v, want ErrNotStructPointer", s, err)
+		}
+		if err := RegisterFlags(fs, s); !errors.Is(err, ErrNotStruct) {
+			t.Errorf("RegisterFlags(%#v) = %v, want ErrNotStruct", s, err)
+		}
+	}
+	wantErr := `unable to bind flags for "nil": not a pointer to a struct`
+	if err := Bind(flag.NewFlagSet("testflags", flag.ContinueOnError), nil); fmt.Sprintf("%v", err) != wantErr {
+		t.Errorf("unexpected error from Bind; got %v want %v", err, wantErr)
+	}
+}
+
+func TestOnlySetFlags(t *testing.T) {
+	type config struct {
+		Name  string            `flag:"name" default:"flag-default"`
+		Port  int               `flag:"port"`
+		Hosts []string          `flag:"hosts"`
+		Tags  map[string]string `flag:"tags"`
+	}
+	flags := flag.NewFlagSet("testflags", flag.ContinueOnError)
+	if err := RegisterFlags(flags, config{}); err != nil {
+		t.Fatalf("unexpected error from RegisterFlags: %v", err)
+	}
+	if err := flags.Parse([]string{"--port=8080", "--tags=a=b"}); err != nil {
+		t.Fatalf("unexpected error from Parse: %v", err)
+	}
+	cfg := config{Name: "from-file", Port: 80, Hosts: []string{"x"}}
+	if err := LoadFromFlags(flags, &cfg, OnlySetFlags()); err != nil {
+		t.Fatalf("unexpected error from LoadFromFlags: %v", err)
+	}
+	want := config{Name: "from-file", Port: 8080, Hosts: []string{"x"}, Tags: map[string]string{"a": "b"}}
+	if !deepEqual(cfg, want) {
+		t.Errorf("unexpected output from LoadFromFlags; got %#v want %#v", cfg, want)
+	}
+	if err := LoadFromFlags(flags, &cfg); err != nil {
+		t.Fatalf("unexpected error from LoadFromFlags: %v", err)
+	}
+	if cfg.Name != "flag-default" || len(cfg.Hosts) != 0 {
+		t.Errorf("LoadFromFlags without OnlySetFlags kept unset fields: %#v", cfg)
+	}
+}
+
+func TestStructPrefix(t *testing.T) {
+	type server struct {
+		Host string `flag:"host"`
+		Port int    `flag:"port"`
+	}
+	type Common struct {
+		Debug bool `flag:"debug"`
+	}
+	type tls struct {
+		Cert string `flag:"cert"`
+	}
+	type config struct {
+		Common
+		DB     server `flagprefix:"db."`
+		Cache  server
+		Client struct {
+			TLS tls
+		}
+		Plain server `flagprefix:""`
+	}
+	names := func(opts ...Option) []string {
+		flags := flag.NewFlagSet("testflags", flag.ContinueOnError)
+		if err := RegisterFlags(flags, config{}, opts...); err != nil {
+			t.Fatalf("unexpected error from RegisterFlags: %v", err)
+		}
+		var ret []string
+		flags.VisitAll(func(f *flag.Flag) {
+			ret = append(ret, f.Name)
+		})
+		return ret
+	}
+	want := []string{"app.cache.host", "app.cache.port", "app.client.tls.cert", "app.db.host", "app.db.port", "app.debug", "app.host", "app.port"}
+	if got := names(AutoPrefix(), FlagPrefix("app.")); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected flags with AutoPrefix; got %q want %q", got, want)
+	}
+
+	flags := flag.NewFlagSet("testflags", flag.ContinueOnError)
+	if err := RegisterFlags(flags, config{}, AutoPrefix()); err != nil {
+		t.Fatal(err)
+	}
+	if err := flags.Parse([]string{"--db.host=db", "--cache.host=cache", "--host=plain", "--client.tls.cert=c"}); err != nil {
+		t.Fatal(err)
+	}
+	var cfg config
+	if err := LoadFromFlags(flags, &cfg, AutoPrefix()); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DB.Host != "db" || cfg.Cache.Host != "cache" || cfg.Plain.Host != "plain" || cfg.Client.TLS.Cert != "c" {
+		t.Errorf("unexpected output from LoadFromFlags: %#v", cfg)
+	}
+}
+
+func TestTextValueCopy(t *testing.T) {
+	type config struct {
+		Big  big.Int  `flag:"big"`
+		PBig *big.Int `flag:"pbig"`
+	}
+	cfg := config{PBig: big.NewInt(12345)}
+	cfg.Big.SetInt64(67890)
+	flags := flag.NewFlagSet("testflags", flag.ContinueOnError)
+	if err := RegisterFlags(flags, cfg); err != nil {
+		t.Fatalf("unexpected error from RegisterFlags: %v", err)
+	}
+	if err := flags.Parse([]string{"--big=11", "--pbig=22"}); err != nil {
+		t.Fatalf("unexpected error from Parse: %v", err)
+	}
+	if cfg.Big.Int64() != 67890 || cfg.PBig.Int64() != 12345 {
+		t.Errorf("parsing flags modified the struct passed to RegisterFlags: %v %v", &cfg.Big, cfg.PBig)
+	}
+	var got config
+	if err := LoadFromFlags(flags, &got); err != nil {
+		t.Fatalf("unexpected error from LoadFromFlags: %v", err)
+	}
+	if got.Big.Int64() != 11 || got.PBig.Int64() != 22 {
+		t.Errorf("unexpected output from LoadFromFlags: %v %v", &got.Big, got.PBig)
+	}
+	// The loaded values do not share memory with the flags.
+	if err := flags.Set("pbig", "33"); err != nil {
+		t.Fatal(err)
+	}
+	if got.PBig.Int64() != 22 {
+		t.Errorf("setting a flag modified a loaded value: %v", got.PBig)
+	}
+}
+
+func TestPlanCache(t *testing.T) {
+	type config struct {
+		A int      `flag:"a"`
+		B []string `flag:"b"`
+	}
+	typ := reflect.TypeOf(config{})
+	// Start empty so that no plan is cleared before the checks below.
+	planCache.Lock()
+	planCache.m = map[planKey][]cachedPlan{}
+	planCache.n = 0
+	planCache.Unlock()
+	p1, err := getPlan(typ, getOpts(), "register")
+	if err != nil {
+		t.Fatalf("unexpected error from getPlan: %v", err)
+	}
+	p2, _ := getPlan(typ, getOpts(), "register")
+	if p1 != p2 {
+		t.Errorf("expected cached plan to be reused")
+	}
+	planCache.RLock()
+	n := planCache.n
+	planCache.RUnlock()
+	p3, _ := getPlan(typ, getOpts(FlagPrefix("x_")), "register")
+	if p3 == p1 || p3.fields[0].name != "x_a" || p1.fields[0].name != "a" {
+		t.Errorf("expected a distinct plan for different options")
+	}
+	for i := 0; i < 10; i++ {
+		p, _ := getPlan(typ, getOpts(FlagPrefix(fmt.Sprintf("tenant%d_", i)), EnvPrefix(fmt.Sprintf("T%d_", i))), "register")
+		if want := fmt.Sprintf("T%d_TENANT%d_A", i, i); p.fields[0].env != want {
+			t.Errorf("unexpected env name %q, want %q", p.fields[0].env, want)
+		}
+	}
+	planCache.RLock()
+	if planCache.n != n {
+		t.Errorf("prefixes added %d plans to the cache", planCache.n-n)
+	}
+	planCache.RUnlock()
+	p4, _ := getPlan(typ, getOpts(FlagType(new(customType), newCustomFlag)), "register")
+	if p4 == p1 {
+		t.Errorf("expected a distinct plan for different registered types")
+	}
+	p5, _ := getPlan(typ, getOpts(FlagType(int(0), newIntValue)), "register")
+	if p5 != p1 {
+		t.Errorf("expected plan to be reused when a built in type is re-registered")
+	}
+
+	for i := 0; i < maxCachedPlans+10; i++ {
+		getPlan(typ, getOpts(HelpTagName(fmt.Sprintf("help%d", i))), "register")
+	}
+	planCache.RLock()
+	if planCache.n > maxCachedPlans {
+		t.Errorf("plan cache holds %d plans, more than %d", planCache.n, maxCachedPlans)
+	}
+	planCache.RUnlock()
+	// Plans are compiled again once the cache has been cleared.
+	p6, err := getPlan(typ, getOpts(), "register")
+	if err != nil || len(p6.fields) != 2 || p6.fields[0].name != "a" {
+		t.Errorf("unexpected plan after the cache was cleared: %+v, %v", p6, err)
+	}
+}
+
+type benchConfig struct {
+	Name     string            `flag:"name" default:"bench"`
+	Port     int               `flag:"port" default:"8080"`
+	Timeout  time.Duration     `flag:"timeout" default:"5s"`
+	Verbose  *bool             `flag:"verbose"`
+	Hosts    []string          `flag:"hosts" default:"a,b,c"`
+	Weights  []float64         `flag:"weights"`
+	Labels   map[string]string `flag:"labels"`
+	Retries  uint32            `flag:"retries"`
+	Nested   nested
+	MaxBytes int64 `flag:"max_bytes"`
+}
+
+func BenchmarkRegisterFlags(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		flags := flag.NewFlagSet("bench", flag.ContinueOnError)
+		if err := RegisterFlags(flags, benchConfig{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkBind(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		var cfg benchConfig
+		flags := flag.NewFlagSet("bench", flag.ContinueOnError)
+		if err := Bind(flags, &cfg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLoadFromFlags(b *testing.B) {
+	flags := flag.NewFlagSet("bench", flag.ContinueOnError)
+	if err := RegisterFlags(flags, benchConfig{}); err != nil {
+		b.Fatal(err)
+	}
+	if err := flags.Parse([]string{"--port=9090", "--hosts=x,y", "--labels=a=b"}); err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var cfg benchConfig
+		if err := LoadFromFlags(flags, &cfg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCompilePlan(b *testing.B) {
+	typ := reflect.TypeOf(benchConfig{})
+	opts := getOpts()
+	for i := 0; i < b.N; i++ {
+		if _, err := compilePlan(typ, opts, "register"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package reflectflag
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+)
+
+// ResolutionKind describes how the flag.Getter for a type is created.
+type ResolutionKind int
+
+const (
+	// ResolvedFlagType indicates a factory registered with FlagType (or one of
+	// the built in types) is used.
+	ResolvedFlagType ResolutionKind = iota
+	// ResolvedFlagValue indicates the type implements flag.Value and is used
+	// directly.
+	ResolvedFlagValue
+	// ResolvedTextUnmarshaler indicates the type implements
+	// encoding.TextUnmarshaler.
+	ResolvedTextUnmarshaler
+)
+
+func (k ResolutionKind) String() string {
+	switch k {
+	case ResolvedFlagType:
+		return "FlagType"
+	case ResolvedFlagValue:
+		return "flag.Value"
+	case ResolvedTextUnmarshaler:
+		return "encoding.TextUnmarshaler"
+	}
+	return fmt.Sprintf("ResolutionKind(%d)", int(k))
+}
+
+// Resolution describes how the flag for a struct field was created.
+type Resolution struct {
+	// Flag is the name of the flag.
+	Flag string
+	// Field is the dotted path of Go field names to the field.
+	Field string
+	// Type is the type that was resolved. For slice, map and Optional fields
+	// this is the element type.
+	Type reflect.Type
+	// Kind describes how the flag.Getter is created.
+	Kind ResolutionKind
+	// Registered is the type passed to FlagType when Kind is
+	// ResolvedFlagType, otherwise it is the Type with all pointer indirection
+	// removed.
+	Registered reflect.Type
+	// Hops is the number of pointer indirections between Type and Registered.
+	Hops int
+}
+
+func (r Resolution) String() string {
+	return fmt.Sprintf("flag %q (field %s, type %v): %v %v, %d pointer hops", r.Flag, r.Field, r.Type, r.Kind, r.Registered, r.Hops)
+}
+
+// Explain reports how the flag.Getter of every flag associated with the struct
+// s is chosen. s may be a struct or a pointer to a struct.
+//
+// For a given type the registered FlagTypes are considered first. Of those
+// that differ from the type only by pointer indirection an exact match is
+// preferred, followed by the one requiring the fewest pointer hops, followed
+// by the earliest registered. The built in types are registered before any
+// FlagType Options. If no FlagType matches, a type whose pointer implements
+// flag.Value is used directly, and failing that one implementing
+// encoding.TextUnmarshaler.
+func Explain(s interface{}, opts ...Option) ([]Resolution, error) {
+	typ := reflect.TypeOf(s)
+	if typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("unable to explain flags for %q: %w", reflect.TypeOf(s), ErrNotStruct)
+	}
+	o := getOpts(opts...)
+	p, err := getPlan(typ, o, "explain")
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]Resolution, 0, len(p.fields))
+	for _, fp := range p.fields {
+		t := fp.field.Type
+		if fp.container != reflect.Invalid {
+			t = baseType(t).Elem()
+		} else if elem, ok := optionalElem(t); ok {
+			t = elem
+		}
+		ret = append(ret, Resolution{
+			Flag:       fp.name,
+			Field:      fp.path,
+			Type:       t,
+			Kind:       fp.res.kind,
+			Registered: fp.res.typ,
+			Hops:       fp.res.hops,
+		})
+	}
+	return ret, nil
+}
+
+// resolution records the way a flag.Getter is created for a type. It refers to
+// registered factories by index so that it remains valid for any options that
+// register the same types in the same order.
+type resolution struct {
+	kind ResolutionKind
+	typ  reflect.Type // the registered type, or the base type
+	idx  int          // index into options.ftypes when kind is ResolvedFlagType
+	hops int
+}
+
+// newGetter returns a flag.Getter initialized with v, which must differ from
+// the resolved type only by pointer indirection.
+func (r resolution) newGetter(v reflect.Value, opts options) flag.Getter {
+	cv, err := convertValueTo(v, r.typ)
+	if err != nil {
+		return nil
+	}
+	switch r.kind {
+	case ResolvedFlagValue:
+		return newFieldFlagValue(cv)
+	case ResolvedTextUnmarshaler:
+		return newTextValue(cv)
+	}
+	return opts.ftypes[r.idx].factory(cv.Interface())
+}
+
+// resolveType determines how the flag.Getter for typ is created. The
+// precedence is documented on Explain.
+func resolveType(typ reflect.Type, opts options) (resolution, bool) {
+	base := baseType(typ)
+	depth := ptrDepth(typ)
+	best := resolution{hops: -1}
+	for i, ft := range opts.ftypes {
+		if baseType(ft.typ) != base {
+			continue
+		}
+		hops := depth - ptrDepth(ft.typ)
+		if hops < 0 {
+			hops = -hops
+		}
+		if best.hops < 0 || hops < best.hops {
+			best = resolution{kind: ResolvedFlagType, typ: ft.typ, idx: i, hops: hops}
+		}
+	}
+	if best.hops >= 0 {
+		return best, true
+	}
+	switch ptr := reflect.PtrTo(base); {
+	case ptr.Implements(flagValueType):
+		return resolution{kind: ResolvedFlagValue, typ: base, hops: depth}, true
+	case ptr.Implements(textUnmarshalerType):
+		return resolution{kind: ResolvedTextUnmarshaler, typ: base, hops: depth}, true
+	}
+	return resolution{}, false
+}
+
+// ptrDepth returns the number of pointer indirections in typ.
+func ptrDepth(typ reflect.Type) int {
+	n := 0
+	for typ.Kind() == reflect.Ptr {
+		n++
+		typ = typ.Elem()
+	}
+	return n
+}
@@ -0,0 +1,93 @@
+package reflectflag
+
+import (
+	"flag"
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+type taggedValue struct {
+	stringValue
+	factory string
+}
+
+func taggedFactory(name string) FlagGetterFactory {
+	return func(interface{}) flag.Getter {
+		return &taggedValue{factory: name}
+	}
+}
+
+func TestResolveType(t *testing.T) {
+	type T int
+	opts := getOpts(
+		FlagType(T(0), taggedFactory("T")),
+		FlagType(ptrTo(ptrTo(T(0))), taggedFactory("**T")),
+		FlagType(ptrTo(T(0)), taggedFactory("*T")),
+	)
+	for _, tc := range []struct {
+		in   interface{}
+		want string
+	}{
+		{in: T(0), want: "T"},
+		{in: ptrTo(T(0)), want: "*T"},
+		{in: ptrTo(ptrTo(T(0))), want: "**T"},
+		{in: ptrTo(ptrTo(ptrTo(T(0)))), want: "**T"},
+	} {
+		for i := 0; i < 20; i++ {
+			// map iteration order made this random in the past
+			g := flagGetterForValue(reflect.ValueOf(tc.in), opts)
+			if got := g.(*taggedValue).factory; got != tc.want {
+				t.Fatalf("unexpected factory for %T; got %s want %s", tc.in, got, tc.want)
+			}
+		}
+	}
+
+	// With equal hops the earliest registration wins.
+	opts = getOpts(
+		FlagType(T(0), taggedFactory("T")),
+		FlagType(ptrTo(ptrTo(T(0))), taggedFactory("**T")),
+	)
+	if got := flagGetterForValue(reflect.ValueOf(ptrTo(T(0))), opts).(*taggedValue).factory; got != "T" {
+		t.Errorf("unexpected factory for *T; got %s want T", got)
+	}
+
+	// Registering a type again replaces its factory.
+	opts = getOpts(FlagType(int(0), taggedFactory("int")))
+	if _, ok := flagGetterForValue(reflect.ValueOf(3), opts).(*taggedValue); !ok {
+		t.Errorf("built in int factory was not replaced")
+	}
+}
+
+func TestExplain(t *testing.T) {
+	type inner struct {
+		Addrs []netip.Addr `flag:"addrs"`
+	}
+	type config struct {
+		Port  *int       `flag:"port"`
+		Upper upperValue `flag:"upper"`
+		Inner inner
+	}
+	got, err := Explain(&config{}, FlagPrefix("x_"))
+	if err != nil {
+		t.Fatalf("unexpected error from Explain: %v", err)
+	}
+	want := []string{
+		`flag "x_port" (field Port, type *int): FlagType int, 1 pointer hops`,
+		`flag "x_upper" (field Upper, type reflectflag.upperValue): flag.Value reflectflag.upperValue, 0 pointer hops`,
+		`flag "x_addrs" (field Inner.Addrs, type netip.Addr): encoding.TextUnmarshaler netip.Addr, 0 pointer hops`,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("unexpected number of resolutions; got %v want %v", got, want)
+	}
+	for i := range want {
+		if got[i].String() != want[i] {
+			t.Errorf("unexpected resolution; got %s want %s", got[i], want[i])
+		}
+	}
+	if _, err := Explain(struct {
+		C chan int `flag:"c"`
+	}{}); err == nil {
+		t.Errorf("expected an error from Explain for an unsupported type")
+	}
+}
@@ -0,0 +1,139 @@
+package reflectflag
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ResponseFiles enables expansion of "@path" arguments by Parse, as performed
+// by ExpandResponseFiles.
+func ResponseFiles() Option {
+	return responseFilesOpt{}
+}
+
+type responseFilesOpt struct{}
+
+func (responseFilesOpt) set(opts *options) {
+	opts.responseFiles = true
+}
+
+// ExpandResponseFiles returns args with every argument of the form "@path"
+// replaced by the arguments contained in the file at path. Arguments in the
+// file are separated by whitespace and may be quoted as in a POSIX shell:
+// single quotes preserve their contents literally, double quotes allow
+// backslash escapes, and an unquoted backslash escapes the following
+// character. A word starting with an unquoted "#" begins a comment that extends
+// to the end of the line. Files may reference other files; relative paths in a
+// file are resolved from the directory of that file. Arguments following "--",
+// on the command line or within a file, are not expanded.
+func ExpandResponseFiles(args []string) ([]string, error) {
+	ret, _, err := expandResponseFiles(args, "", nil)
+	return ret, err
+}
+
+// expandResponseFiles expands the response files in args. done reports whether
+// a "--" was encountered, after which the caller must not expand any further
+// arguments.
+func expandResponseFiles(args []string, dir string, active []string) (ret []string, done bool, err error) {
+	for i, arg := range args {
+		if arg == "--" {
+			return append(ret, args[i:]...), true, nil
+		}
+		if len(arg) < 2 || arg[0] != '@' {
+			ret = append(ret, arg)
+			continue
+		}
+		path := arg[1:]
+		if dir != "" && !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, a := range active {
+			if a == abs {
+				return nil, false, fmt.Errorf("%s: response file include cycle", path)
+			}
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, false, err
+		}
+		tokens, err := splitShellWords(string(data))
+		if err != nil {
+			return nil, false, fmt.Errorf("%s: %w", path, err)
+		}
+		expanded, done, err := expandResponseFiles(tokens, filepath.Dir(path), append(active, abs))
+		if err != nil {
+			return nil, false, err
+		}
+		ret = append(ret, expanded...)
+		if done {
+			return append(ret, args[i+1:]...), true, nil
+		}
+	}
+	return ret, false, nil
+}
+
+// splitShellWords splits s into words using POSIX shell quoting rules.
+func splitShellWords(s string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case c == '#' && !inWord:
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+		case c == '\\':
+			inWord = true
+			i++
+			if i < len(s) && s[i] != '\n' {
+				word.WriteByte(s[i])
+			}
+		case c == '\'':
+			inWord = true
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated single quote")
+			}
+			word.WriteString(s[i+1 : i+1+end])
+			i += end + 1
+		case c == '"':
+			inWord = true
+			for i++; ; i++ {
+				if i == len(s) {
+					return nil, fmt.Errorf("unterminated double quote")
+				}
+				if s[i] == '"' {
+					break
+				}
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("\\\"$`\n", s[i+1]) >= 0 {
+					i++
+					if s[i] == '\n' {
+						continue
+					}
+				}
+				word.WriteByte(s[i])
+			}
+		default:
+			inWord = true
+			word.WriteByte(c)
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
@@ -0,0 +1,86 @@
+package reflectflag
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExpandResponseFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, contents string) string {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	args := write("args.rsp", `
+# launcher arguments
+--name 'hello world' --msg="say \"hi\"" plain\ space
+@sub/more.rsp
+`)
+	write("sub/more.rsp", "--port=80 '' # trailing comment\n")
+	cycle := write("cycle.rsp", "@sub/cycle.rsp\n")
+	write("sub/cycle.rsp", "@../cycle.rsp\n")
+	unterminated := write("bad.rsp", "--name 'oops\n")
+	dashes := write("dashes.rsp", "--port=1 -- @x\n")
+
+	tests := []struct {
+		args []string
+		want []string
+		err  string
+	}{
+		{
+			args: []string{"-a", "@" + args, "-b"},
+			want: []string{"-a", "--name", "hello world", `--msg=say "hi"`, "plain space", "--port=80", "", "-b"},
+		},
+		{
+			args: []string{"@", "--", "@" + args},
+			want: []string{"@", "--", "@" + args},
+		},
+		{
+			args: []string{"@" + dashes, "@" + args},
+			want: []string{"--port=1", "--", "@x", "@" + args},
+		},
+		{args: []string{"@" + cycle}, err: "response file include cycle"},
+		{args: []string{"@" + unterminated}, err: "bad.rsp: unterminated single quote"},
+		{args: []string{"@" + filepath.Join(dir, "missing.rsp")}, err: "missing.rsp"},
+	}
+	for _, test := range tests {
+		got, err := ExpandResponseFiles(test.args)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("ExpandResponseFiles(%q) error = %v, want %q", test.args, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ExpandResponseFiles(%q) unexpected error: %v", test.args, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ExpandResponseFiles(%q) = %q, want %q", test.args, got, test.want)
+		}
+	}
+
+	type config struct {
+		Name string `flag:"name"`
+		Port int    `flag:"port"`
+	}
+	cfg, err := Parse[config]([]string{"@" + write("parse.rsp", "--name 'a b' --port 8")}, ResponseFiles())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "a b" || cfg.Port != 8 {
+		t.Errorf("Parse with ResponseFiles = %+v", cfg)
+	}
+	if _, err := Parse[config]([]string{"@" + cycle}, ResponseFiles()); err == nil {
+		t.Errorf("Parse with ResponseFiles: expected cycle error")
+	}
+}
@@ -0,0 +1,147 @@
+package reflectflag
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// DeprecationOutput sets the writer that receives a warning whenever a flag
+// whose tag marks it as deprecated is set. By default RegisterFlags and Bind
+// warn on the output of the FlagSet, while Parse and Loader, which print
+// nothing else, warn on os.Stderr.
+func DeprecationOutput(w io.Writer) Option {
+	return deprecationOutputOpt{w}
+}
+
+type deprecationOutputOpt struct{ w io.Writer }
+
+func (o deprecationOutputOpt) set(opts *options) {
+	opts.deprecatedOut = o.w
+}
+
+// specValue wraps the flag.Value of a field whose tag marks it as hidden or
+// deprecated.
+type specValue struct {
+	wrappedValue
+	flags      *flag.FlagSet
+	out        io.Writer // destination of deprecation warnings, or nil for flags.Output()
+	name       string
+	hidden     bool
+	deprecated string
+}
+
+func (s *specValue) Set(val string) error {
+	if s.deprecated != "" {
+		out := s.out
+		if out == nil {
+			out = s.flags.Output()
+		}
+		fmt.Fprintf(out, "flag -%s is deprecated: %s\n", s.name, s.deprecated)
+	}
+	return s.Value.Set(val)
+}
+
+// aliasValue is the flag.Value of the short name of a flag. Setting it sets the
+// flag it is an alias of, so that FlagSet.Visit reports that flag as set.
+type aliasValue struct {
+	flags  *flag.FlagSet
+	target string
+}
+
+func (a *aliasValue) Set(val string) error {
+	return a.flags.Set(a.target, val)
+}
+
+func (a *aliasValue) String() string {
+	return a.targetValue().String()
+}
+
+func (a *aliasValue) IsBoolFlag() bool {
+	return a.targetValue().IsBoolFlag()
+}
+
+// targetValue returns the current value of the flag a is an alias of, which
+// is looked up on every use since the flag's Value may be replaced.
+func (a *aliasValue) targetValue() wrappedValue {
+	if a.flags == nil {
+		return wrappedValue{}
+	}
+	return wrappedValue{a.flags.Lookup(a.target).Value}
+}
+
+// registerAlias registers the short name of the flag described by fp.
+func registerAlias(flags *flag.FlagSet, fp *fieldPlan, opts options) {
+	av := &aliasValue{flags: flags, target: fp.name}
+	usage := "Shorthand for -" + fp.name
+	if f := flags.Lookup(fp.spec.short); f != nil {
+		if opts.duplicates == DuplicateSkip {
+			return
+		}
+		f.Value = av
+		f.Usage = usage
+		f.DefValue = av.String()
+		return
+	}
+	flags.Var(av, fp.spec.short, usage)
+}
+
+// isHidden reports whether the flag f is left out of PrintDefaults.
+func isHidden(flags *flag.FlagSet, f *flag.Flag) bool {
+	if av, ok := f.Value.(*aliasValue); ok {
+		target := flags.Lookup(av.target)
+		return target == nil || isHidden(flags, target)
+	}
+	for v := f.Value; ; {
+		if sv, ok := v.(*specValue); ok {
+			return sv.hidden || sv.deprecated != ""
+		}
+		next, ok := unwrapOnce(v)
+		if !ok {
+			return false
+		}
+		v = next
+	}
+}
+
+// PrintDefaults prints the usage of the flags in the FlagSet to its output,
+// like FlagSet.PrintDefaults, leaving out flags whose tag marks them as hidden
+// or deprecated. FlagSet.PrintDefaults itself lists every flag. When hidden or
+// deprecated flags are registered on a FlagSet with the default Usage function
+// of flag.NewFlagSet, its Usage is replaced by one that prints the usage with
+// this function; other Usage functions, such as that of flag.CommandLine,
+// should call it themselves.
+func PrintDefaults(flags *flag.FlagSet) {
+	visible := flag.NewFlagSet(flags.Name(), flag.ContinueOnError)
+	visible.SetOutput(flags.Output())
+	flags.VisitAll(func(f *flag.Flag) {
+		if isHidden(flags, f) {
+			return
+		}
+		visible.Var(f.Value, f.Name, f.Usage)
+		visible.Lookup(f.Name).DefValue = f.DefValue
+	})
+	visible.PrintDefaults()
+}
+
+// defaultUsage identifies the Usage function installed by flag.NewFlagSet.
+// Method values of the same method share their code pointer.
+var defaultUsage = reflect.ValueOf(flag.NewFlagSet("", flag.ContinueOnError).Usage).Pointer()
+
+// hasDefaultUsage reports whether the Usage function of the FlagSet is unset or
+// is the one installed by flag.NewFlagSet.
+func hasDefaultUsage(flags *flag.FlagSet) bool {
+	return flags.Usage == nil || reflect.ValueOf(flags.Usage).Pointer() == defaultUsage
+}
+
+// printUsage prints the usage message of the FlagSet like its default Usage
+// function, but with PrintDefaults.
+func printUsage(flags *flag.FlagSet) {
+	if flags.Name() == "" {
+		fmt.Fprintf(flags.Output(), "Usage:\n")
+	} else {
+		fmt.Fprintf(flags.Output(), "Usage of %s:\n", flags.Name())
+	}
+	PrintDefaults(flags)
+}
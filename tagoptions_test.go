@@ -0,0 +1,163 @@
+package reflectflag
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseFlagTag(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want fieldSpec
+		err  string
+	}{
+		{tag: "name", want: fieldSpec{name: "name"}},
+		{tag: ",required", want: fieldSpec{required: true}},
+		{
+			tag:  "name,required,hidden,short=n,sep=;,deprecated=use -other,env=APP_NAME",
+			want: fieldSpec{name: "name", required: true, hidden: true, short: "n", sep: ';', deprecated: "use -other", env: "APP_NAME"},
+		},
+		{tag: "name,bogus", err: `unknown option "bogus" in tag "name,bogus"`},
+		{tag: "name,bogus=1", err: `unknown option "bogus=1" in tag "name,bogus=1"`},
+		{tag: "name,hidden=yes", err: `option "hidden" in tag "name,hidden=yes" does not take a value`},
+		{tag: "name,short", err: `option "short" in tag "name,short" requires a value`},
+		{tag: "name,env=", err: `option "env" in tag "name,env=" requires a value`},
+		{tag: "name,short=xyz", err: `invalid short name "xyz" in tag "name,short=xyz"`},
+		{tag: "name,short=-", err: `invalid short name "-" in tag "name,short=-"`},
+		{tag: "name,short=é", want: fieldSpec{name: "name", short: "é"}},
+		{tag: "name,sep=ab", err: `invalid separator "ab" in tag "name,sep=ab"`},
+		{tag: `name,sep="`, err: `invalid separator "\"" in tag "name,sep=\""`},
+	}
+	for _, test := range tests {
+		got, err := parseFlagTag(test.tag)
+		if test.err != "" {
+			if fmt.Sprintf("%v", err) != test.err {
+				t.Errorf("parseFlagTag(%q) error = %v, want %v", test.tag, err, test.err)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("parseFlagTag(%q) = %+v, %v, want %+v", test.tag, got, err, test.want)
+		}
+	}
+}
+
+func TestTagOptions(t *testing.T) {
+	type config struct {
+		Name    string            `flag:"name,short=n,required"`
+		Verbose bool              `flag:"verbose,short=v"`
+		Hosts   []string          `flag:"hosts,sep=;"`
+		Labels  map[string]string `flag:"labels,sep=|"`
+		Secret  string            `flag:"secret,hidden,env=TEST_TAG_SECRET"`
+		Old     int               `flag:"old,deprecated=use -name instead"`
+	}
+	t.Setenv("TEST_TAG_SECRET", "s3cret")
+	var out bytes.Buffer
+	flags := flag.NewFlagSet("testflags", flag.ContinueOnError)
+	flags.SetOutput(&out)
+	if err := RegisterFlags(flags, config{}); err != nil {
+		t.Fatalf("unexpected error from RegisterFlags: %v", err)
+	}
+	if err := flags.Parse([]string{"-n", "x", "-v", "--hosts=a,b;c", "--labels=k=1|j=2", "--old=3"}); err != nil {
+		t.Fatalf("unexpected error from Parse: %v", err)
+	}
+	var cfg config
+	if err := LoadFromFlags(flags, &cfg); err != nil {
+		t.Fatalf("unexpected error from LoadFromFlags: %v", err)
+	}
+	want := config{
+		Name:    "x",
+		Verbose: true,
+		Hosts:   []string{"a,b", "c"},
+		Labels:  map[string]string{"k": "1", "j": "2"},
+		Secret:  "s3cret",
+		Old:     3,
+	}
+	if !deepEqual(cfg, want) {
+		t.Errorf("unexpected output from LoadFromFlags; got %#v want %#v", cfg, want)
+	}
+	if got := out.String(); got != "flag -old is deprecated: use -name instead\n" {
+		t.Errorf("unexpected deprecation warning: %q", got)
+	}
+	if got := flags.Lookup("hosts").Value.String(); got != "a,b;c" {
+		t.Errorf("unexpected String of a slice with sep=;: %q", got)
+	}
+
+	out.Reset()
+	PrintDefaults(flags)
+	usage := out.String()
+	for _, name := range []string{"-name", "-n ", "-verbose", "-v\t", "-hosts", "-labels"} {
+		if !strings.Contains(usage, name) {
+			t.Errorf("PrintDefaults is missing %s:\n%s", name, usage)
+		}
+	}
+	for _, name := range []string{"-secret", "-old"} {
+		if strings.Contains(usage, name) {
+			t.Errorf("PrintDefaults includes %s:\n%s", name, usage)
+		}
+	}
+
+	// The default usage message leaves out hidden flags too.
+	out.Reset()
+	flags.Usage()
+	if got := out.String(); got != "Usage of testflags:\n"+usage {
+		t.Errorf("unexpected output from Usage; got %q want %q", got, "Usage of testflags:\n"+usage)
+	}
+	out.Reset()
+	flags = flag.NewFlagSet("testflags", flag.ContinueOnError)
+	flags.SetOutput(&out)
+	if err := Bind(flags, &config{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := flags.Parse([]string{"-h"}); err != flag.ErrHelp {
+		t.Errorf("unexpected error from Parse; got %v want %v", err, flag.ErrHelp)
+	}
+	if got := out.String(); !strings.Contains(got, "-name") || strings.Contains(got, "-secret") {
+		t.Errorf("unexpected usage from Parse with -h:\n%s", got)
+	}
+	// A Usage function that is already set is left alone.
+	called := false
+	flags = flag.NewFlagSet("testflags", flag.ContinueOnError)
+	flags.Usage = func() { called = true }
+	if err := RegisterFlags(flags, config{}); err != nil {
+		t.Fatal(err)
+	}
+	if flags.Usage(); !called {
+		t.Errorf("RegisterFlags replaced the Usage function of the FlagSet")
+	}
+
+	// Config files join lists with the separator of the flag.
+	flags = flag.NewFlagSet("testflags", flag.ContinueOnError)
+	if err := RegisterFlags(flags, config{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadJSON(flags, strings.NewReader(`{"name": "y", "hosts": ["a,b", "c"], "labels": {"k": "1"}}`)); err != nil {
+		t.Fatalf("unexpected error from LoadJSON: %v", err)
+	}
+	cfg = config{}
+	if err := LoadFromFlags(flags, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Hosts) != 2 || cfg.Hosts[0] != "a,b" || cfg.Labels["k"] != "1" {
+		t.Errorf("unexpected output after LoadJSON: %#v", cfg)
+	}
+
+	type shortConflict struct {
+		A string `flag:"alpha,short=x"`
+		B string `flag:"x"`
+	}
+	wantErr := `unable to register flag for field reflectflag.shortConflict.B: flag "x" is also used by field reflectflag.shortConflict.A`
+	if err := RegisterFlags(flag.NewFlagSet("testflags", flag.ContinueOnError), shortConflict{}); fmt.Sprintf("%v", err) != wantErr {
+		t.Errorf("unexpected error from RegisterFlags; got %v want %v", err, wantErr)
+	}
+	type badSep struct {
+		A string `flag:"a,sep=;"`
+	}
+	wantErr = `unable to register flag for field reflectflag.badSep.A: option "sep" requires a slice or map field`
+	if err := RegisterFlags(flag.NewFlagSet("testflags", flag.ContinueOnError), badSep{}); fmt.Sprintf("%v", err) != wantErr {
+		t.Errorf("unexpected error from RegisterFlags; got %v want %v", err, wantErr)
+	}
+}
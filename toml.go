@@ -0,0 +1,268 @@
+package reflectflag
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type tomlSource struct{}
+
+func (tomlSource) Decode(r io.Reader) (map[string]interface{}, error) {
+	out := map[string]interface{}{}
+	prefix := ""
+	scanner := bufio.NewScanner(r)
+	for num := 1; scanner.Scan(); num++ {
+		start := num
+		line := strings.TrimSpace(stripTOMLComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[[") {
+			return nil, fmt.Errorf("line %d: arrays of tables are not supported", num)
+		}
+		if line[0] == '[' {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: invalid table header", num)
+			}
+			keys, err := parseTOMLKey(line[1 : len(line)-1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", num, err)
+			}
+			prefix = strings.Join(keys, ".") + "."
+			continue
+		}
+		// Arrays may span multiple lines.
+		for tomlDepth(line) > 0 && scanner.Scan() {
+			num++
+			line += "\n" + strings.TrimSpace(stripTOMLComment(scanner.Text()))
+		}
+		if err := parseTOMLKeyValue(out, prefix, line); err != nil {
+			return nil, fmt.Errorf("line %d: %w", start, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// parseTOMLKeyValue parses a "key = value" expression and adds its value to
+// out, flattening inline tables.
+func parseTOMLKeyValue(out map[string]interface{}, prefix, s string) error {
+	parts := splitOutsideQuotes(s, '=')
+	if len(parts) < 2 {
+		return fmt.Errorf("expected 'key = value'")
+	}
+	keys, err := parseTOMLKey(parts[0])
+	if err != nil {
+		return err
+	}
+	name := prefix + strings.Join(keys, ".")
+	raw := strings.TrimSpace(s[len(parts[0])+1:])
+	if strings.HasPrefix(raw, "{") {
+		if !strings.HasSuffix(raw, "}") {
+			return fmt.Errorf("unterminated inline table")
+		}
+		inner := strings.TrimSpace(raw[1 : len(raw)-1])
+		if inner == "" {
+			return nil
+		}
+		for _, kv := range splitTOMLList(inner) {
+			if err := parseTOMLKeyValue(out, name+".", kv); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	v, err := parseTOMLValue(raw)
+	if err != nil {
+		return err
+	}
+	if _, ok := out[name]; ok {
+		return fmt.Errorf("duplicate key %q", name)
+	}
+	out[name] = v
+	return nil
+}
+
+// parseTOMLKey parses a bare, quoted or dotted key into its parts.
+func parseTOMLKey(s string) ([]string, error) {
+	var keys []string
+	for _, part := range splitOutsideQuotes(s, '.') {
+		part = strings.TrimSpace(part)
+		switch {
+		case part == "":
+			return nil, fmt.Errorf("invalid key %q", strings.TrimSpace(s))
+		case part[0] == '"' || part[0] == '\'':
+			k, err := parseTOMLString(part)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, k)
+		default:
+			for _, r := range part {
+				if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+					return nil, fmt.Errorf("invalid key %q", strings.TrimSpace(s))
+				}
+			}
+			keys = append(keys, part)
+		}
+	}
+	return keys, nil
+}
+
+// parseTOMLValue parses a scalar or an array of scalars. Scalars other than
+// strings are returned as written, to be interpreted by the flag.
+func parseTOMLValue(s string) (interface{}, error) {
+	switch {
+	case s == "":
+		return nil, fmt.Errorf("missing value")
+	case s[0] == '[':
+		if !strings.HasSuffix(s, "]") {
+			return nil, fmt.Errorf("unterminated array")
+		}
+		list := []string{}
+		inner := strings.TrimSpace(s[1 : len(s)-1])
+		for _, item := range splitTOMLList(inner) {
+			v, err := parseTOMLValue(item)
+			if err != nil {
+				return nil, err
+			}
+			str, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("nested arrays are not supported")
+			}
+			list = append(list, str)
+		}
+		return list, nil
+	case s[0] == '{':
+		return nil, fmt.Errorf("inline tables are not supported in arrays")
+	case s[0] == '"' || s[0] == '\'':
+		return parseTOMLString(s)
+	}
+	if strings.ContainsAny(s, "\"'[]{}=\n") {
+		return nil, fmt.Errorf("invalid value %q", s)
+	}
+	return s, nil
+}
+
+// parseTOMLString parses a single line basic or literal string.
+func parseTOMLString(s string) (string, error) {
+	if strings.HasPrefix(s, `"""`) || strings.HasPrefix(s, "'''") {
+		return "", fmt.Errorf("multi-line strings are not supported")
+	}
+	if len(s) < 2 {
+		return "", fmt.Errorf("unterminated string %s", s)
+	}
+	if s[0] == '\'' {
+		end := strings.IndexByte(s[1:], '\'')
+		if end != len(s)-2 {
+			return "", fmt.Errorf("invalid literal string %s", s)
+		}
+		return s[1 : len(s)-1], nil
+	}
+	if closingQuote(s) != len(s)-1 {
+		return "", fmt.Errorf("invalid string %s", s)
+	}
+	v, err := unescape(s[1:len(s)-1], tomlEscapes, tomlHexEscapes)
+	if err != nil {
+		return "", fmt.Errorf("invalid string %s: %w", s, err)
+	}
+	return v, nil
+}
+
+// The escape sequences of TOML basic strings.
+var (
+	tomlEscapes = map[byte]string{
+		'b': "\b", 't': "\t", 'n': "\n", 'f': "\f", 'r': "\r", '"': "\"", '\\': "\\",
+	}
+	tomlHexEscapes = map[byte]int{'u': 4, 'U': 8}
+)
+
+// stripTOMLComment removes a "#" comment from line. Unlike YAML, a "#" outside
+// of a string always starts a comment.
+func stripTOMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// splitTOMLList splits the comma separated contents of an array or inline
+// table, ignoring a trailing comma and any newlines.
+func splitTOMLList(s string) []string {
+	var items []string
+	depth := 0
+	start := 0
+	var quote byte
+	for i := 0; i <= len(s); i++ {
+		if i < len(s) {
+			c := s[i]
+			switch {
+			case quote == '"' && c == '\\':
+				i++
+				continue
+			case quote != 0:
+				if c == quote {
+					quote = 0
+				}
+				continue
+			case c == '"' || c == '\'':
+				quote = c
+				continue
+			case c == '[' || c == '{':
+				depth++
+				continue
+			case c == ']' || c == '}':
+				depth--
+				continue
+			case c != ',' || depth > 0:
+				continue
+			}
+		}
+		if item := strings.TrimSpace(s[start:i]); item != "" {
+			items = append(items, item)
+		}
+		start = i + 1
+	}
+	return items
+}
+
+// tomlDepth returns the number of unclosed brackets in s.
+func tomlDepth(s string) int {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		}
+	}
+	return depth
+}
@@ -0,0 +1,207 @@
+package reflectflag
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Format is a config file format written by WriteFlags and WriteConfig.
+type Format int
+
+// The formats understood by WriteFlags. FormatFlagFile is the format read by
+// the FlagFile flag.
+const (
+	FormatJSON Format = iota
+	FormatYAML
+	FormatTOML
+	FormatFlagFile
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatJSON:
+		return "json"
+	case FormatYAML:
+		return "yaml"
+	case FormatTOML:
+		return "toml"
+	case FormatFlagFile:
+		return "flagfile"
+	}
+	return fmt.Sprintf("Format(%d)", int(f))
+}
+
+// WriteFlags writes the current value of every flag in the FlagSet to w in the
+// given format, sorted by flag name. Every value is written as the string
+// returned by its String method, so loading the output with LoadFile, or with
+// the FlagFile flag, restores the same values. The usage text of each flag is
+// written as a comment in the formats that support them. The flag registered
+// by the FlagFile Option is not written, nor are short names or the flags of
+// empty Optional fields. Values that cannot be represented in the format, such
+// as invalid UTF-8 in JSON, YAML and TOML, are an error.
+func WriteFlags(w io.Writer, flags *flag.FlagSet, format Format) error {
+	var all []*flag.Flag
+	flags.VisitAll(func(f *flag.Flag) {
+		switch f.Value.(type) {
+		case *flagFileValue, *aliasValue:
+			return
+		}
+		if ov, ok := unwrapValue(f.Value).(*optionalValue); ok && !ov.set {
+			return
+		}
+		all = append(all, f)
+	})
+	if format != FormatFlagFile {
+		for _, f := range all {
+			if val := f.Value.String(); !utf8.ValidString(val) {
+				return fmt.Errorf("unable to write flag %q: value %q is not valid UTF-8", f.Name, val)
+			}
+		}
+	}
+	bw := bufio.NewWriter(w)
+	var err error
+	switch format {
+	case FormatJSON:
+		err = writeJSON(bw, all)
+	case FormatYAML, FormatTOML:
+		sep, quote := ": ", strconv.Quote
+		if format == FormatTOML {
+			sep, quote = " = ", quoteTOML
+		}
+		for _, f := range all {
+			writeComment(bw, f.Usage)
+			fmt.Fprintf(bw, "%s%s%s\n", configKey(f.Name, quote), sep, quote(f.Value.String()))
+		}
+	case FormatFlagFile:
+		for _, f := range all {
+			val := f.Value.String()
+			if strings.ContainsAny(val, "\r\n") || strings.TrimSpace(val) != val {
+				return fmt.Errorf("unable to write flag %q: value %q cannot be represented in a flagfile", f.Name, val)
+			}
+			writeComment(bw, f.Usage)
+			fmt.Fprintf(bw, "--%s=%s\n", f.Name, val)
+		}
+	default:
+		return fmt.Errorf("unknown format %v", format)
+	}
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// WriteConfig writes the fields of s, which may be a struct or a pointer to a
+// struct, to w in the given format as described by WriteFlags. The values are
+// those of the fields of s; default tags and the environment are ignored.
+func WriteConfig(w io.Writer, s interface{}, format Format, opts ...Option) error {
+	v := reflect.ValueOf(s)
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("unable to write config for %q: %w", reflect.TypeOf(s), ErrNotStruct)
+	}
+	o := getOpts(opts...)
+	p, err := getPlan(v.Type(), o, "write")
+	if err != nil {
+		return err
+	}
+	o.skipDefaults = true
+	o.skipEnv = true
+	flags := newFlagSet()
+	if err := registerPlan(flags, v, p, o); err != nil {
+		return err
+	}
+	return WriteFlags(w, flags, format)
+}
+
+func writeJSON(w io.Writer, all []*flag.Flag) error {
+	if len(all) == 0 {
+		_, err := io.WriteString(w, "{}\n")
+		return err
+	}
+	io.WriteString(w, "{\n")
+	for i, f := range all {
+		name, err := json.Marshal(f.Name)
+		if err != nil {
+			return err
+		}
+		val, err := json.Marshal(f.Value.String())
+		if err != nil {
+			return err
+		}
+		sep := ","
+		if i == len(all)-1 {
+			sep = ""
+		}
+		fmt.Fprintf(w, "  %s: %s%s\n", name, val, sep)
+	}
+	_, err := io.WriteString(w, "}\n")
+	return err
+}
+
+// writeComment writes usage as a "#" comment.
+func writeComment(w io.Writer, usage string) {
+	if usage == "" {
+		return
+	}
+	for _, line := range strings.Split(usage, "\n") {
+		fmt.Fprintf(w, "# %s\n", line)
+	}
+}
+
+// configKey returns name as a YAML or TOML key, quoting it with quote unless it
+// is made up of characters that are allowed in a bare key. A bare dotted key
+// names the same flag as a quoted one once the file is decoded.
+func configKey(name string, quote func(string) string) string {
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-' || r == '.') {
+			return quote(name)
+		}
+	}
+	if name == "" || name[0] == '.' || name[len(name)-1] == '.' || strings.Contains(name, "..") || name[0] == '-' {
+		return quote(name)
+	}
+	return name
+}
+
+// quoteTOML returns s as a TOML basic string. Unlike strconv.Quote it only uses
+// the escape sequences TOML defines, writing other control characters as
+// \uXXXX. s must be valid UTF-8.
+func quoteTOML(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,105 @@
+package reflectflag
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteConfig(t *testing.T) {
+	type inner struct {
+		Host string `flag:"db.host" help:"Database host"`
+	}
+	type config struct {
+		Name    string            `flag:"name" help:"Name to greet\nover two lines"`
+		Quote   string            `flag:"quote"`
+		Ctrl    string            `flag:"ctrl"`
+		Port    *int              `flag:"port" default:"80"`
+		Timeout time.Duration     `flag:"timeout"`
+		Hosts   []string          `flag:"hosts"`
+		Labels  map[string]string `flag:"labels"`
+		Verbose bool              `flag:"verbose"`
+		DB      inner
+	}
+	port := 8080
+	cfg := config{
+		Name:    "world",
+		Quote:   `it's "quoted" # not a comment`,
+		Ctrl:    "a\ab\vc\x01\x7f\u0085\u2028\\ é\t",
+		Port:    &port,
+		Timeout: 90 * time.Second,
+		Hosts:   []string{"a", "b,c"},
+		Labels:  map[string]string{"env": "prod", "team": "infra"},
+		Verbose: true,
+		DB:      inner{Host: "localhost"},
+	}
+	dir := t.TempDir()
+	for _, format := range []Format{FormatJSON, FormatYAML, FormatTOML, FormatFlagFile} {
+		var b bytes.Buffer
+		if format == FormatFlagFile {
+			// Values containing quotes and '#' are fine, but not newlines.
+			if err := WriteConfig(&b, &config{Name: "a\nb"}, format); err == nil {
+				t.Errorf("expected an error from WriteConfig(%v) with a newline", format)
+			}
+		} else if err := WriteConfig(&b, &config{Name: "a\xffb"}, format); err == nil {
+			t.Errorf("expected an error from WriteConfig(%v) with invalid UTF-8", format)
+		}
+		b.Reset()
+		want := cfg
+		if format == FormatFlagFile {
+			// Surrounding whitespace cannot be written in a flagfile.
+			want.Ctrl = ""
+		}
+		if err := WriteConfig(&b, &want, format); err != nil {
+			t.Fatalf("unexpected error from WriteConfig(%v): %v", format, err)
+		}
+		if format != FormatJSON && !strings.Contains(b.String(), "# Name to greet\n# over two lines\n") {
+			t.Errorf("WriteConfig(%v) is missing usage comments:\n%s", format, b.String())
+		}
+
+		fs := newFlagSet()
+		var got config
+		if err := RegisterFlags(fs, got, FlagFile("flagfile")); err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, "config."+format.String())
+		if err := os.WriteFile(path, b.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if format == FormatFlagFile {
+			err := fs.Parse([]string{"--flagfile=" + path})
+			if err != nil {
+				t.Fatalf("unable to load %v output: %v\n%s", format, err, b.String())
+			}
+		} else if err := LoadFile(fs, path); err != nil {
+			t.Fatalf("unable to load %v output: %v\n%s", format, err, b.String())
+		}
+		if err := LoadFromFlags(fs, &got); err != nil {
+			t.Fatal(err)
+		}
+		if !deepEqual(got, want) {
+			t.Errorf("%v round trip: got %#v want %#v\n%s", format, got, want, b.String())
+		}
+
+		// Writing the FlagSet produces the same output, without the
+		// flagfile flag.
+		var b2 bytes.Buffer
+		if err := WriteFlags(&b2, fs, format); err != nil {
+			t.Fatal(err)
+		}
+		if b2.String() != b.String() {
+			t.Errorf("WriteFlags(%v) = %s, want %s", format, b2.String(), b.String())
+		}
+	}
+
+	var b bytes.Buffer
+	if err := WriteConfig(&b, 1, FormatJSON); err == nil {
+		t.Errorf("expected an error from WriteConfig with a non-struct")
+	}
+	if err := WriteConfig(&b, cfg, Format(99)); err == nil {
+		t.Errorf("expected an error from WriteConfig with an unknown format")
+	}
+}
@@ -0,0 +1,365 @@
+package reflectflag
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+type yamlSource struct{}
+
+// yamlLine is a significant line of a YAML document with comments removed.
+type yamlLine struct {
+	num    int
+	indent int
+	text   string
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+	out   map[string]interface{}
+}
+
+func (yamlSource) Decode(r io.Reader) (map[string]interface{}, error) {
+	p := &yamlParser{out: map[string]interface{}{}}
+	scanner := bufio.NewScanner(r)
+	for num := 1; scanner.Scan(); num++ {
+		line := scanner.Text()
+		trimmed := strings.TrimLeft(line, " ")
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", num)
+		}
+		text := strings.TrimRight(stripComment(trimmed), " \t")
+		if text == "" || text == "---" || text == "..." {
+			continue
+		}
+		p.lines = append(p.lines, yamlLine{num: num, indent: len(line) - len(trimmed), text: text})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(p.lines) == 0 {
+		return p.out, nil
+	}
+	if err := p.parseMapping(p.lines[0].indent, ""); err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		return nil, p.errorf(p.lines[p.pos], "unexpected indentation")
+	}
+	return p.out, nil
+}
+
+func (p *yamlParser) errorf(l yamlLine, format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: "+format, append([]interface{}{l.num}, args...)...)
+}
+
+func (p *yamlParser) set(l yamlLine, name string, v interface{}) error {
+	if _, ok := p.out[name]; ok {
+		return p.errorf(l, "duplicate key %q", name)
+	}
+	p.out[name] = v
+	return nil
+}
+
+// parseMapping parses the block mapping whose keys are at the given indent,
+// naming its values with prefix.
+func (p *yamlParser) parseMapping(indent int, prefix string) error {
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if l.indent < indent {
+			return nil
+		}
+		if l.indent > indent {
+			return p.errorf(l, "unexpected indentation")
+		}
+		if isYAMLSeqItem(l.text) {
+			return p.errorf(l, "unexpected sequence item")
+		}
+		key, rest, err := splitYAMLKey(l.text)
+		if err != nil {
+			return p.errorf(l, "%w", err)
+		}
+		p.pos++
+		name := prefix + key
+		if rest != "" {
+			v, err := parseYAMLValue(rest)
+			if err != nil {
+				return p.errorf(l, "%w", err)
+			}
+			if v != nil {
+				if err := p.set(l, name, v); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if p.pos == len(p.lines) {
+			continue
+		}
+		next := p.lines[p.pos]
+		switch {
+		case isYAMLSeqItem(next.text) && next.indent >= indent:
+			list, err := p.parseSequence(next.indent)
+			if err != nil {
+				return err
+			}
+			if err := p.set(l, name, list); err != nil {
+				return err
+			}
+		case next.indent > indent:
+			if err := p.parseMapping(next.indent, name+"."); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// parseSequence parses a block sequence of scalars whose items are at the
+// given indent.
+func (p *yamlParser) parseSequence(indent int) ([]string, error) {
+	list := []string{}
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if l.indent != indent || !isYAMLSeqItem(l.text) {
+			break
+		}
+		p.pos++
+		item := strings.TrimSpace(strings.TrimPrefix(l.text, "-"))
+		if !strings.HasPrefix(item, "\"") && !strings.HasPrefix(item, "'") {
+			if _, _, err := splitYAMLKey(item); err == nil {
+				return nil, p.errorf(l, "sequences of mappings are not supported")
+			}
+		}
+		v, err := parseYAMLScalar(item)
+		if err != nil {
+			return nil, p.errorf(l, "%w", err)
+		}
+		list = append(list, v)
+	}
+	if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+		return nil, p.errorf(p.lines[p.pos], "nested sequences are not supported")
+	}
+	return list, nil
+}
+
+func isYAMLSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// splitYAMLKey splits a "key: value" line into its key and the remaining
+// value text, which is empty if the value is a nested block.
+func splitYAMLKey(text string) (string, string, error) {
+	if text == "" {
+		return "", "", fmt.Errorf("missing key")
+	}
+	if text[0] == '"' || text[0] == '\'' {
+		end := closingQuote(text)
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated quoted key")
+		}
+		key, err := parseYAMLScalar(text[:end+1])
+		if err != nil {
+			return "", "", err
+		}
+		rest := text[end+1:]
+		if !strings.HasPrefix(rest, ":") || (len(rest) > 1 && rest[1] != ' ') {
+			return "", "", fmt.Errorf("expected ':' after key")
+		}
+		return key, strings.TrimSpace(rest[1:]), nil
+	}
+	for i := 0; i < len(text); i++ {
+		if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ') {
+			return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:]), nil
+		}
+	}
+	return "", "", fmt.Errorf("expected 'key: value'")
+}
+
+// parseYAMLValue parses the value of a mapping entry. It returns a string, a
+// []string for flow sequences, or nil for null.
+func parseYAMLValue(s string) (interface{}, error) {
+	switch {
+	case s == "~" || s == "null" || s == "Null" || s == "NULL":
+		return nil, nil
+	case s[0] == '[':
+		if !strings.HasSuffix(s, "]") {
+			return nil, fmt.Errorf("unterminated flow sequence")
+		}
+		list := []string{}
+		inner := strings.TrimSpace(s[1 : len(s)-1])
+		if inner == "" {
+			return list, nil
+		}
+		for _, item := range splitOutsideQuotes(inner, ',') {
+			item = strings.TrimSpace(item)
+			if strings.HasPrefix(item, "[") || strings.HasPrefix(item, "{") {
+				return nil, fmt.Errorf("nested collections are not supported")
+			}
+			v, err := parseYAMLScalar(item)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	case s[0] == '{':
+		return nil, fmt.Errorf("flow mappings are not supported")
+	case s[0] == '|' || s[0] == '>':
+		return nil, fmt.Errorf("block scalars are not supported")
+	case s[0] == '&' || s[0] == '*' || s[0] == '!':
+		return nil, fmt.Errorf("anchors, aliases and tags are not supported")
+	}
+	return parseYAMLScalar(s)
+}
+
+// parseYAMLScalar parses a plain, single quoted or double quoted scalar.
+func parseYAMLScalar(s string) (string, error) {
+	if s == "" {
+		return "", nil
+	}
+	switch s[0] {
+	case '"':
+		if closingQuote(s) != len(s)-1 {
+			return "", fmt.Errorf("invalid double quoted string %s", s)
+		}
+		v, err := unescape(s[1:len(s)-1], yamlEscapes, yamlHexEscapes)
+		if err != nil {
+			return "", fmt.Errorf("invalid double quoted string %s: %w", s, err)
+		}
+		return v, nil
+	case '\'':
+		if closingQuote(s) != len(s)-1 {
+			return "", fmt.Errorf("invalid single quoted string %s", s)
+		}
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	}
+	return s, nil
+}
+
+// The escape sequences of YAML double quoted scalars.
+var (
+	yamlEscapes = map[byte]string{
+		'0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", '\t': "\t", 'n': "\n", 'v': "\v",
+		'f': "\f", 'r': "\r", 'e': "\x1b", ' ': " ", '"': "\"", '/': "/", '\\': "\\",
+		'N': "\u0085", '_': "\u00a0", 'L': "\u2028", 'P': "\u2029",
+	}
+	yamlHexEscapes = map[byte]int{'x': 2, 'u': 4, 'U': 8}
+)
+
+// unescape replaces the backslash escape sequences in s. escapes maps the
+// character following a backslash to its replacement, and hexEscapes maps it
+// to the number of hexadecimal digits of a code point that follow it.
+func unescape(s string, escapes map[byte]string, hexEscapes map[byte]int) (string, error) {
+	if !strings.Contains(s, "\\") {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+1 == len(s) {
+			return "", fmt.Errorf("trailing backslash")
+		}
+		i++
+		if r, ok := escapes[s[i]]; ok {
+			b.WriteString(r)
+			continue
+		}
+		n, ok := hexEscapes[s[i]]
+		if !ok {
+			return "", fmt.Errorf("invalid escape sequence \\%c", s[i])
+		}
+		if i+n >= len(s) {
+			return "", fmt.Errorf("invalid escape sequence \\%s", s[i:])
+		}
+		code, err := strconv.ParseUint(s[i+1:i+1+n], 16, 32)
+		if err != nil {
+			return "", fmt.Errorf("invalid escape sequence \\%s: %w", s[i:i+1+n], err)
+		}
+		if !utf8.ValidRune(rune(code)) {
+			return "", fmt.Errorf("invalid escape sequence \\%s", s[i:i+1+n])
+		}
+		b.WriteRune(rune(code))
+		i += n
+	}
+	return b.String(), nil
+}
+
+// closingQuote returns the index of the quote that terminates the quoted
+// string at the start of s, or -1 if there is none. Double quoted strings use
+// backslash escapes; single quoted strings escape a quote by doubling it, as
+// in YAML.
+func closingQuote(s string) int {
+	q := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case q == '"' && s[i] == '\\':
+			i++
+		case s[i] == q && q == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			i++
+		case s[i] == q:
+			return i
+		}
+	}
+	return -1
+}
+
+// stripComment removes a trailing "#" comment from line. A "#" only starts a
+// comment at the start of the line or after whitespace, outside of quotes.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && startsScalar(line, i):
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// startsScalar reports whether the quote at s[i] starts a quoted string rather
+// than appearing within a plain value, as in "it's".
+func startsScalar(s string, i int) bool {
+	return i == 0 || strings.IndexByte(" \t[,{:=", s[i-1]) >= 0
+}
+
+// splitOutsideQuotes splits s at every sep that is not within quotes.
+func splitOutsideQuotes(s string, sep byte) []string {
+	var parts []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && startsScalar(s, i):
+			quote = c
+		case c == sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}